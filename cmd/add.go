@@ -34,6 +34,7 @@ func init() {
 	rootCmd.AddCommand(addCmd)
 
 	persistentFlags := addCmd.PersistentFlags()
+	persistentFlags.BoolVar(&config.add.options.Create, "create", false, "add files that should only be created if they do not exist")
 	persistentFlags.BoolVarP(&config.add.options.Empty, "empty", "e", false, "add empty files")
 	persistentFlags.BoolVar(&config.add.options.Encrypt, "encrypt", false, "encrypt files")
 	persistentFlags.BoolVarP(&config.add.force, "force", "f", false, "overwrite source state, even if template would be lost")
@@ -59,6 +60,9 @@ func (c *Config) runAddCmd(cmd *cobra.Command, args []string) (err error) {
 	if err := c.ensureSourceDirectory(); err != nil {
 		return err
 	}
+	if err := vfs.MkdirAll(c.mutator, ts.SourceDir, 0o777&^os.FileMode(c.Umask)); err != nil {
+		return err
+	}
 	destDirPrefix := filepath.FromSlash(ts.DestDir + "/")
 	var quit int // quit is an int with a unique address
 	defer func() {
@@ -285,6 +285,26 @@ func TestAddCommand(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "add_create_file",
+			args: []string{"/home/user/.config"},
+			add: addCmdConfig{
+				options: chezmoi.AddOptions{
+					Create: true,
+				},
+			},
+			root: map[string]interface{}{
+				"/home/user":                      &vfst.Dir{Perm: 0o755},
+				"/home/user/.local/share/chezmoi": &vfst.Dir{Perm: 0o700},
+				"/home/user/.config":              "# contents of .config\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/create_dot_config",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# contents of .config\n"),
+				),
+			},
+		},
 		{
 			name: "add_empty_file_in_subdir",
 			args: []string{"/home/user/subdir/empty"},
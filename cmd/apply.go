@@ -1,7 +1,20 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
 	"github.com/spf13/cobra"
+	vfs "github.com/twpayne/go-vfs"
+
+	"github.com/twpayne/chezmoi/internal/chezmoi"
 )
 
 var applyCmd = &cobra.Command{
@@ -13,9 +26,25 @@ var applyCmd = &cobra.Command{
 	RunE:    config.runApplyCmd,
 }
 
+type applyCmdConfig struct {
+	exportScript     string
+	force            bool
+	format           string
+	interactive      bool
+	refreshExternals bool
+}
+
 func init() {
 	rootCmd.AddCommand(applyCmd)
 
+	persistentFlags := applyCmd.PersistentFlags()
+	persistentFlags.StringVar(&config.apply.exportScript, "export-script", "", "write a shell script that makes the changes to file, requires --dry-run")
+	persistentFlags.BoolVar(&config.apply.force, "force", false, "overwrite targets modified since the last apply")
+	persistentFlags.StringVar(&config.apply.format, "format", "", "print the plan in format (JSON or YAML), requires --dry-run")
+	persistentFlags.BoolVarP(&config.apply.interactive, "interactive", "i", false, "prompt before each change")
+	persistentFlags.BoolVar(&config.apply.refreshExternals, "refresh-externals", false, "refresh externals")
+	panicOnError(applyCmd.MarkPersistentFlagFilename("export-script"))
+
 	markRemainingZshCompPositionalArgumentsAsFiles(applyCmd, 1)
 }
 
@@ -26,5 +55,302 @@ func (c *Config) runApplyCmd(cmd *cobra.Command, args []string) error {
 	}
 	defer persistentState.Close()
 
-	return c.applyArgs(args, persistentState)
+	switch {
+	case c.apply.exportScript != "" && c.apply.format != "":
+		return errors.New("--export-script and --format cannot be used together")
+	case c.apply.exportScript != "":
+		return c.exportApplyScript(args, persistentState)
+	case c.apply.format != "":
+		return c.printApplyPlan(args, persistentState)
+	default:
+		return c.applyArgsWithState(args, persistentState)
+	}
+}
+
+// exportApplyScript writes a POSIX shell script that makes the changes that
+// applying args would make, and runs the scripts that it would run, to
+// c.apply.exportScript, or to the standard output if it is -.
+func (c *Config) exportApplyScript(args []string, persistentState chezmoi.PersistentState) error {
+	if !c.DryRun {
+		return errors.New("--export-script requires --dry-run")
+	}
+
+	ts, applyOptions, entries, err := c.getApplyEntries(args, persistentState)
+	if err != nil {
+		return err
+	}
+	shellScriptMutator := chezmoi.NewShellScriptMutator(chezmoi.NullMutator{}, ts.DestDir)
+	if err := c.recordApply(ts, applyOptions, entries, shellScriptMutator, shellScriptMutator.RecordScript); err != nil {
+		return err
+	}
+	if c.apply.exportScript == "-" {
+		_, err := c.Stdout.Write(shellScriptMutator.Script())
+		return err
+	}
+	return c.fs.WriteFile(c.apply.exportScript, shellScriptMutator.Script(), 0o755)
+}
+
+// printApplyPlan prints the operations that applying args would make, and the
+// scripts that it would run, in order, in c.apply.format.
+func (c *Config) printApplyPlan(args []string, persistentState chezmoi.PersistentState) error {
+	if !c.DryRun {
+		return errors.New("--format requires --dry-run")
+	}
+	format, ok := formatMap[strings.ToLower(c.apply.format)]
+	if !ok {
+		return fmt.Errorf("%s: unknown format", c.apply.format)
+	}
+
+	ts, applyOptions, entries, err := c.getApplyEntries(args, persistentState)
+	if err != nil {
+		return err
+	}
+	recordingMutator := chezmoi.NewRecordingMutator(chezmoi.NullMutator{}, vfs.NewReadOnlyFS(c.fs))
+	if err := c.recordApply(ts, applyOptions, entries, recordingMutator, recordingMutator.RecordScript); err != nil {
+		return err
+	}
+	return format(c.Stdout, recordingMutator.Ops())
+}
+
+// recordApply applies entries with mutator, without verbose output, calling
+// recordScript for each script that would run.
+func (c *Config) recordApply(ts *chezmoi.TargetState, applyOptions *chezmoi.ApplyOptions, entries []chezmoi.Entry, mutator chezmoi.Mutator, recordScript func(string, []byte, *chezmoi.Interpreter, []string)) error {
+	c.mutator = mutator
+	applyOptions.RecordScript = recordScript
+	applyOptions.Verbose = false
+	return c.applyEntries(ts, applyOptions, entries)
+}
+
+// resolveDrift finds the targets of entries that have been modified since they
+// were last applied. If c is interactive then it prompts the user to
+// overwrite, skip, or merge each of them, updating applyOptions to ignore the
+// targets that should not be overwritten. Otherwise, it returns an error
+// listing them.
+func (c *Config) resolveDrift(ts *chezmoi.TargetState, applyOptions *chezmoi.ApplyOptions, entries []chezmoi.Entry) error {
+	drifts, err := chezmoi.FindDrift(vfs.NewReadOnlyFS(c.fs), c.Follow, applyOptions, entries)
+	if err != nil {
+		return err
+	}
+	if len(drifts) == 0 {
+		return nil
+	}
+
+	if !c.interactive() {
+		targetPaths := make([]string, 0, len(drifts))
+		for _, drift := range drifts {
+			targetPaths = append(targetPaths, drift.TargetPath)
+		}
+		return fmt.Errorf("targets modified since the last apply, use --force to overwrite:\n  %s", strings.Join(targetPaths, "\n  "))
+	}
+
+	// Create a temporary directory for merges if needed.
+	var tempDir string
+	defer func() {
+		if tempDir != "" {
+			_ = os.RemoveAll(tempDir)
+		}
+	}()
+
+	skip := make(map[string]bool)
+DRIFT:
+	for _, drift := range drifts {
+		for {
+			choice, err := c.prompt(fmt.Sprintf("%s has changed since it was last applied, overwrite, skip, merge, or show diff", drift.TargetPath), "osmd")
+			if err != nil {
+				return err
+			}
+			switch choice {
+			case 'o':
+				continue DRIFT
+			case 's':
+				skip[drift.File.TargetName()] = true
+				continue DRIFT
+			case 'm':
+				if tempDir == "" {
+					tempDir, err = ioutil.TempDir("", "chezmoi")
+					if err != nil {
+						return err
+					}
+				}
+				if err := c.runMergeCommand(ts, drift.TargetPath, drift.File, tempDir); err != nil {
+					return err
+				}
+				// The merge leaves the target as the user wants it.
+				skip[drift.File.TargetName()] = true
+				continue DRIFT
+			case 'd':
+				verboseMutator := chezmoi.NewVerboseMutator(c.Stdout, chezmoi.NullMutator{}, c.colored, c.maxDiffDataSize)
+				if err := verboseMutator.WriteFile(drift.TargetPath, drift.NewContents, drift.File.Perm&^applyOptions.Umask, drift.Contents); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if len(skip) != 0 {
+		ignore := applyOptions.Ignore
+		applyOptions.Ignore = func(targetName string) bool {
+			return skip[targetName] || ignore(targetName)
+		}
+	}
+	return nil
+}
+
+// promptApply shows the diff of each change that applying entries, or all of
+// ts if entries is nil, would make and prompts the user to apply it, skip it,
+// merge it, or quit, which skips all remaining changes. Accepted removals are
+// made immediately. applyOptions is updated to ignore the targets that should
+// not be changed.
+func (c *Config) promptApply(ts *chezmoi.TargetState, applyOptions *chezmoi.ApplyOptions, entries []chezmoi.Entry) error {
+	readOnlyFS := vfs.NewReadOnlyFS(c.fs)
+	quit := false
+
+	if entries == nil {
+		if applyOptions.Remove {
+			targetsToRemove, err := ts.TargetsToRemove(readOnlyFS)
+			if err != nil {
+				return err
+			}
+			// Removals are made here, so ts.Apply must not make them again.
+			applyOptions.Remove = false
+		REMOVE:
+			for _, targetPath := range targetsToRemove {
+				diffMutator, err := c.newDiffMutator(c.Stdout)
+				if err != nil {
+					return err
+				}
+				if err := diffMutator.RemoveAll(targetPath); err != nil {
+					return err
+				}
+				choice, err := c.prompt(fmt.Sprintf("Remove %s", targetPath), "ynq")
+				if err != nil {
+					return err
+				}
+				switch choice {
+				case 'y':
+					if err := c.mutator.RemoveAll(targetPath); err != nil {
+						return err
+					}
+				case 'n':
+				case 'q':
+					quit = true
+					break REMOVE
+				}
+			}
+		}
+		entries = topLevelEntries(ts)
+	}
+
+	// Create a temporary directory for merges if needed.
+	var tempDir string
+	defer func() {
+		if tempDir != "" {
+			_ = os.RemoveAll(tempDir)
+		}
+	}()
+
+	sortedEntries := chezmoi.SortedEntries(entries, applyOptions.Ignore)
+	targetNames := make(map[string]bool, len(sortedEntries))
+	for _, entry := range sortedEntries {
+		targetNames[entry.TargetName()] = true
+	}
+
+	ignore := applyOptions.Ignore
+	skip := make(map[string]bool)
+ENTRY:
+	for _, entry := range sortedEntries {
+		targetName := entry.TargetName()
+		if quit {
+			skip[targetName] = true
+			continue
+		}
+		// The descendants of skipped directories are skipped too.
+		for dir := filepath.Dir(targetName); dir != "."; dir = filepath.Dir(dir) {
+			if skip[dir] {
+				continue ENTRY
+			}
+		}
+
+		// Apply entry, without the descendants that have their own entries, to
+		// a mutator that only records whether it would change anything and
+		// writes the diff. The removal of unmanaged targets in exact
+		// directories is part of the directory's change. Never run scripts,
+		// but print their contents.
+		diffOutput := &bytes.Buffer{}
+		diffMutator, err := c.newDiffMutator(diffOutput)
+		if err != nil {
+			return err
+		}
+		anyMutator := chezmoi.NewAnyMutator(diffMutator)
+		dryRunApplyOptions := *applyOptions
+		dryRunApplyOptions.DryRun = true
+		dryRunApplyOptions.Ignore = func(name string) bool {
+			return name != targetName && targetNames[name] || ignore(name)
+		}
+		dryRunApplyOptions.Stdout = diffOutput
+		dryRunApplyOptions.Verbose = true
+		if err := entry.Apply(readOnlyFS, anyMutator, c.Follow, &dryRunApplyOptions); err != nil {
+			return err
+		}
+		if !anyMutator.Mutated() && diffOutput.Len() == 0 {
+			continue
+		}
+		if _, err := c.Stdout.Write(diffOutput.Bytes()); err != nil {
+			return err
+		}
+
+		file, isFile := entry.(*chezmoi.File)
+		choices := "ynq"
+		if isFile {
+			choices = "ynmq"
+		}
+		choice, err := c.prompt(fmt.Sprintf("Apply %s", applyOptions.TargetPath(targetName)), choices)
+		if err != nil {
+			return err
+		}
+		switch choice {
+		case 'y':
+		case 'n':
+			skip[targetName] = true
+		case 'm':
+			if tempDir == "" {
+				tempDir, err = ioutil.TempDir("", "chezmoi")
+				if err != nil {
+					return err
+				}
+			}
+			if err := c.runMergeCommand(ts, applyOptions.TargetPath(targetName), file, tempDir); err != nil {
+				return err
+			}
+			// The merge leaves the target as the user wants it.
+			skip[targetName] = true
+		case 'q':
+			skip[targetName] = true
+			quit = true
+		}
+	}
+
+	if len(skip) != 0 {
+		applyOptions.Ignore = func(targetName string) bool {
+			return skip[targetName] || ignore(targetName)
+		}
+	}
+	return nil
+}
+
+// newDiffMutator returns a new Mutator that writes the diff of each change to
+// w, in the configured diff format, without making it.
+func (c *Config) newDiffMutator(w io.Writer) (chezmoi.Mutator, error) {
+	switch c.Diff.Format {
+	case "chezmoi":
+		return chezmoi.NewVerboseMutator(w, chezmoi.NullMutator{}, c.colored, c.maxDiffDataSize), nil
+	case "git":
+		unifiedEncoder := diff.NewUnifiedEncoder(w, diff.DefaultContextLines)
+		if c.colored {
+			unifiedEncoder.SetColor(diff.NewColorConfig())
+		}
+		return chezmoi.NewGitDiffMutator(unifiedEncoder, chezmoi.NewFSMutator(vfs.NewReadOnlyFS(c.fs)), c.DestDir+string(filepath.Separator)), nil
+	default:
+		return nil, fmt.Errorf("unknown diff format: %q", c.Diff.Format)
+	}
 }
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"bytes"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -485,3 +488,118 @@ func TestApplyRemoveEmptySymlink(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyDrift(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		force        bool
+		stdin        io.Reader
+		wantErr      bool
+		wantContents string
+	}{
+		{
+			name:         "force",
+			force:        true,
+			wantContents: "# new contents of .bashrc\n",
+		},
+		{
+			name:         "non_interactive",
+			stdin:        os.Stdin,
+			wantErr:      true,
+			wantContents: "# edited .bashrc\n",
+		},
+		{
+			name:         "overwrite",
+			stdin:        strings.NewReader("o\n"),
+			wantContents: "# new contents of .bashrc\n",
+		},
+		{
+			name:         "skip",
+			stdin:        strings.NewReader("s\n"),
+			wantContents: "# edited .bashrc\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user/.local/share/chezmoi/dot_bashrc": "# contents of .bashrc\n",
+			})
+			require.NoError(t, err)
+			defer cleanup()
+			require.NoError(t, newTestConfig(fs).runApplyCmd(nil, nil))
+
+			require.NoError(t, fs.WriteFile("/home/user/.bashrc", []byte("# edited .bashrc\n"), 0o644))
+			require.NoError(t, fs.WriteFile("/home/user/.local/share/chezmoi/dot_bashrc", []byte("# new contents of .bashrc\n"), 0o644))
+
+			options := []configOption{
+				withApplyCmdConfig(applyCmdConfig{
+					force: tc.force,
+				}),
+			}
+			if tc.stdin != nil {
+				options = append(options, withStdin(tc.stdin))
+			}
+			c := newTestConfig(fs, options...)
+			if tc.wantErr {
+				assert.Error(t, c.runApplyCmd(nil, nil))
+			} else {
+				assert.NoError(t, c.runApplyCmd(nil, nil))
+			}
+			vfst.RunTests(t, fs, "",
+				vfst.TestPath("/home/user/.bashrc",
+					vfst.TestContentsString(tc.wantContents),
+				),
+			)
+		})
+	}
+}
+
+func TestApplyInteractive(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		stdin string
+		tests []vfst.Test
+	}{
+		{
+			name:  "apply",
+			stdin: "y\ny\n",
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/dir/extra", vfst.TestDoesNotExist),
+				vfst.TestPath("/home/user/dir/file", vfst.TestContentsString("# contents of dir/file\n")),
+			},
+		},
+		{
+			name:  "skip_dir",
+			stdin: "n\n",
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/dir/extra", vfst.TestModeIsRegular),
+				vfst.TestPath("/home/user/dir/file", vfst.TestDoesNotExist),
+			},
+		},
+		{
+			name:  "skip_file",
+			stdin: "y\nn\n",
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/dir/extra", vfst.TestDoesNotExist),
+				vfst.TestPath("/home/user/dir/file", vfst.TestDoesNotExist),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user/dir/extra":                           "# contents of dir/extra\n",
+				"/home/user/.local/share/chezmoi/exact_dir/file": "# contents of dir/file\n",
+			})
+			require.NoError(t, err)
+			defer cleanup()
+			c := newTestConfig(fs,
+				withApplyCmdConfig(applyCmdConfig{
+					interactive: true,
+				}),
+				withStdin(strings.NewReader(tc.stdin)),
+				withStdout(&bytes.Buffer{}),
+			)
+			assert.NoError(t, c.runApplyCmd(nil, nil))
+			vfst.RunTests(t, fs, "", tc.tests)
+		})
+	}
+}
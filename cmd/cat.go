@@ -43,6 +43,14 @@ func (c *Config) runCatCmd(cmd *cobra.Command, args []string) error {
 			if _, err := c.Stdout.Write(contents); err != nil {
 				return err
 			}
+		case *chezmoi.Script:
+			contents, err := entry.Contents()
+			if err != nil {
+				return err
+			}
+			if _, err := c.Stdout.Write(contents); err != nil {
+				return err
+			}
 		case *chezmoi.Symlink:
 			linkname, err := entry.Linkname()
 			if err != nil {
@@ -50,7 +58,7 @@ func (c *Config) runCatCmd(cmd *cobra.Command, args []string) error {
 			}
 			fmt.Println(linkname)
 		default:
-			return fmt.Errorf("%s: not a file or symlink", args[i])
+			return fmt.Errorf("%s: not a file, script, or symlink", args[i])
 		}
 	}
 	return nil
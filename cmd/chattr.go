@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	vfs "github.com/twpayne/go-vfs"
 
 	"github.com/twpayne/chezmoi/internal/chezmoi"
 )
@@ -23,26 +25,37 @@ var chattrCmd = &cobra.Command{
 	PostRunE: config.autoCommitAndAutoPush,
 }
 
+type chattrCmdConfig struct {
+	attributesFile bool
+}
+
 type boolModifier int
 
 type attributeModifiers struct {
+	create     boolModifier
 	empty      boolModifier
 	encrypted  boolModifier
 	exact      boolModifier
 	executable boolModifier
 	private    boolModifier
+	readOnly   boolModifier
 	template   boolModifier
 }
 
 func init() {
 	rootCmd.AddCommand(chattrCmd)
 
+	persistentFlags := chattrCmd.PersistentFlags()
+	persistentFlags.BoolVar(&config.chattr.attributesFile, "attributes-file", false, "write attributes to "+chezmoi.AttributesName)
+
 	attributes := []string{
+		"create",
 		"empty", "e",
 		"encrypted",
 		"exact",
 		"executable", "x",
 		"private", "p",
+		"readonly", "r",
 		"template", "t",
 	}
 	words := make([]string, 0, 4*len(attributes))
@@ -69,10 +82,29 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if c.chattr.attributesFile {
+		return c.chattrAttributesFile(ts, ams, entries)
+	}
+
 	updates := make(map[string]func() error)
 	for _, entry := range entries {
 		dir, oldBase := filepath.Split(entry.SourceName())
+		// Entries whose source names begin with a dot are populated from
+		// externals and have no attributes.
+		if strings.HasPrefix(oldBase, ".") {
+			return fmt.Errorf("%s: cannot change attributes of external", entry.TargetName())
+		}
 		oldpath := filepath.Join(ts.SourceDir, dir, oldBase)
+		sourcePath := filepath.Join(ts.EntrySourceDir(entry), entry.SourceName())
+		rename := c.mutator.Rename
+		if sourcePath != oldpath {
+			// The entry is in another source directory, so override it by
+			// copying it to the writable source directory with its new
+			// attributes.
+			rename = func(_, newpath string) error {
+				return c.copySourcePath(sourcePath, newpath)
+			}
+		}
 		switch entry := entry.(type) {
 		case *chezmoi.Dir:
 			da := chezmoi.ParseDirAttributes(oldBase)
@@ -81,12 +113,15 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 			if private := ams.private.modify(entry.Private()); private {
 				perm &= 0o700
 			}
+			if readOnly := ams.readOnly.modify(entry.ReadOnly()); readOnly {
+				perm &^= 0o222
+			}
 			da.Perm = perm
 			newBase := da.SourceName()
 			if newBase != oldBase {
 				newpath := filepath.Join(ts.SourceDir, dir, newBase)
 				updates[oldpath] = func() error {
-					return c.mutator.Rename(oldpath, newpath)
+					return rename(oldpath, newpath)
 				}
 			}
 		case *chezmoi.File:
@@ -98,13 +133,20 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 			if private := ams.private.modify(entry.Private()); private {
 				mode &= 0o700
 			}
+			if readOnly := ams.readOnly.modify(entry.ReadOnly()); readOnly {
+				mode &^= 0o222
+			}
 			fa.Mode = mode
+			fa.Create = ams.create.modify(entry.Create)
+			if fa.Create && fa.Modify {
+				return fmt.Errorf("%s: cannot set create attribute of modify_ file", entry.TargetName())
+			}
 			fa.Encrypted = ams.encrypted.modify(entry.Encrypted)
 			fa.Empty = ams.empty.modify(entry.Empty)
 			fa.Template = ams.template.modify(entry.Template)
 			newpath := filepath.Join(ts.SourceDir, dir, fa.SourceName())
 			if fa.Encrypted != entry.Encrypted {
-				oldContents, err := c.fs.ReadFile(filepath.Join(c.SourceDir, entry.SourceName()))
+				oldContents, err := c.fs.ReadFile(sourcePath)
 				if err != nil {
 					return err
 				}
@@ -127,7 +169,37 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 				}
 			} else if newpath != oldpath {
 				updates[oldpath] = func() error {
-					return c.mutator.Rename(oldpath, newpath)
+					return rename(oldpath, newpath)
+				}
+			}
+		case *chezmoi.Script:
+			sa := chezmoi.ParseScriptAttributes(oldBase)
+			sa.Encrypted = ams.encrypted.modify(entry.Encrypted)
+			sa.Template = ams.template.modify(entry.Template)
+			newpath := filepath.Join(ts.SourceDir, dir, sa.SourceName())
+			if sa.Encrypted != entry.Encrypted {
+				oldContents, err := c.fs.ReadFile(sourcePath)
+				if err != nil {
+					return err
+				}
+				var newContents []byte
+				if sa.Encrypted {
+					newContents, err = ts.GPG.Encrypt(entry.TargetName(), oldContents)
+				} else {
+					newContents, err = ts.GPG.Decrypt(entry.TargetName(), oldContents)
+				}
+				if err != nil {
+					return err
+				}
+				updates[oldpath] = func() error {
+					if err := c.mutator.WriteFile(newpath, newContents, 0o644, oldContents); err != nil {
+						return err
+					}
+					return c.mutator.RemoveAll(oldpath)
+				}
+			} else if newpath != oldpath {
+				updates[oldpath] = func() error {
+					return rename(oldpath, newpath)
 				}
 			}
 		case *chezmoi.Symlink:
@@ -137,7 +209,7 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 			if newBase != oldBase {
 				newpath := filepath.Join(ts.SourceDir, dir, newBase)
 				updates[oldpath] = func() error {
-					return c.mutator.Rename(oldpath, newpath)
+					return rename(oldpath, newpath)
 				}
 			}
 		}
@@ -160,6 +232,89 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// chattrAttributesFile changes the attributes of entries by appending rules to
+// the attributes file in the source directory, instead of renaming them.
+func (c *Config) chattrAttributesFile(ts *chezmoi.TargetState, ams *attributeModifiers, entries []chezmoi.Entry) error {
+	path := filepath.Join(ts.SourceDir, chezmoi.AttributesName)
+	oldData, err := c.fs.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	newData := append([]byte{}, oldData...)
+	if len(newData) != 0 && newData[len(newData)-1] != '\n' {
+		newData = append(newData, '\n')
+	}
+
+	for _, entry := range entries {
+		// Entries whose source names begin with a dot are populated from
+		// externals and have no attributes.
+		if strings.HasPrefix(filepath.Base(entry.SourceName()), ".") {
+			return fmt.Errorf("%s: cannot change attributes of external", entry.TargetName())
+		}
+		attributes := make(map[string]bool)
+		encrypted := false
+		switch entry := entry.(type) {
+		case *chezmoi.Dir:
+			ams.exact.set(attributes, chezmoi.AttributeExact)
+			ams.private.set(attributes, chezmoi.AttributePrivate)
+			ams.readOnly.set(attributes, chezmoi.AttributeReadOnly)
+		case *chezmoi.File:
+			ams.create.set(attributes, chezmoi.AttributeCreate)
+			ams.empty.set(attributes, chezmoi.AttributeEmpty)
+			ams.encrypted.set(attributes, chezmoi.AttributeEncrypted)
+			ams.executable.set(attributes, chezmoi.AttributeExecutable)
+			ams.private.set(attributes, chezmoi.AttributePrivate)
+			ams.readOnly.set(attributes, chezmoi.AttributeReadOnly)
+			ams.template.set(attributes, chezmoi.AttributeTemplate)
+			if attributes[chezmoi.AttributeCreate] && entry.Modify {
+				return fmt.Errorf("%s: cannot set create attribute of modify_ file", entry.TargetName())
+			}
+			encrypted = entry.Encrypted
+		case *chezmoi.Script:
+			ams.encrypted.set(attributes, chezmoi.AttributeEncrypted)
+			ams.template.set(attributes, chezmoi.AttributeTemplate)
+			encrypted = entry.Encrypted
+		case *chezmoi.Symlink:
+			ams.template.set(attributes, chezmoi.AttributeTemplate)
+		}
+		if len(attributes) == 0 {
+			continue
+		}
+		newData = append(newData, chezmoi.FormatAttributeLine(entry.TargetName(), attributes)...)
+
+		// If the encrypted attribute changes then the contents of the source
+		// file must be encrypted or decrypted.
+		if newEncrypted, ok := attributes[chezmoi.AttributeEncrypted]; ok && newEncrypted != encrypted {
+			sourcePath := filepath.Join(ts.EntrySourceDir(entry), entry.SourceName())
+			oldContents, err := c.fs.ReadFile(sourcePath)
+			if err != nil {
+				return err
+			}
+			var newContents []byte
+			if newEncrypted {
+				newContents, err = ts.GPG.Encrypt(entry.TargetName(), oldContents)
+			} else {
+				newContents, err = ts.GPG.Decrypt(entry.TargetName(), oldContents)
+			}
+			if err != nil {
+				return err
+			}
+			newSourcePath := filepath.Join(ts.SourceDir, entry.SourceName())
+			if err := vfs.MkdirAll(c.mutator, filepath.Dir(newSourcePath), 0o777&^os.FileMode(c.Umask)); err != nil {
+				return err
+			}
+			if err := c.mutator.WriteFile(newSourcePath, newContents, 0o644, oldContents); err != nil {
+				return err
+			}
+		}
+	}
+
+	if bytes.Equal(oldData, newData) {
+		return nil
+	}
+	return c.mutator.WriteFile(path, newData, 0o666&^os.FileMode(c.Umask), oldData)
+}
+
 func parseAttributeModifiers(s string) (*attributeModifiers, error) {
 	ams := &attributeModifiers{}
 	for _, attributeModifier := range strings.Split(s, ",") {
@@ -184,6 +339,8 @@ func parseAttributeModifiers(s string) (*attributeModifiers, error) {
 			attribute = attributeModifier
 		}
 		switch attribute {
+		case "create":
+			ams.create = modifier
 		case "empty", "e":
 			ams.empty = modifier
 		case "encrypted":
@@ -194,6 +351,8 @@ func parseAttributeModifiers(s string) (*attributeModifiers, error) {
 			ams.executable = modifier
 		case "private", "p":
 			ams.private = modifier
+		case "readonly", "r":
+			ams.readOnly = modifier
 		case "template", "t":
 			ams.template = modifier
 		default:
@@ -203,6 +362,13 @@ func parseAttributeModifiers(s string) (*attributeModifiers, error) {
 	return ams, nil
 }
 
+// set sets attributes[name] if bm modifies it.
+func (bm boolModifier) set(attributes map[string]bool, name string) {
+	if bm != 0 {
+		attributes[name] = bm > 0
+	}
+}
+
 func (bm boolModifier) modify(x bool) bool {
 	switch {
 	case bm < 0:
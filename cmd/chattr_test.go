@@ -83,6 +83,42 @@ func TestChattrCommand(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "file_add_create",
+			args: []string{"+create", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"foo": "# contents of ~/foo\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/create_foo",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# contents of ~/foo\n"),
+				),
+			},
+		},
+		{
+			name: "file_remove_create",
+			args: []string{"-create", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"create_private_foo": "# contents of ~/foo\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/private_foo",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# contents of ~/foo\n"),
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/create_private_foo",
+					vfst.TestDoesNotExist,
+				),
+			},
+		},
 		{
 			name: "file_add_empty",
 			args: []string{"+empty", "/home/user/foo"},
@@ -301,6 +337,9 @@ func TestParseAttributeModifiers(t *testing.T) {
 		want    *attributeModifiers
 		wantErr bool
 	}{
+		{s: "create", want: &attributeModifiers{create: 1}},
+		{s: "-create", want: &attributeModifiers{create: -1}},
+		{s: "nocreate", want: &attributeModifiers{create: -1}},
 		{s: "empty", want: &attributeModifiers{empty: 1}},
 		{s: "+empty", want: &attributeModifiers{empty: 1}},
 		{s: "-empty", want: &attributeModifiers{empty: -1}},
@@ -24,13 +24,17 @@ import (
 	vfs "github.com/twpayne/go-vfs"
 	xdg "github.com/twpayne/go-xdg/v3"
 	bolt "go.etcd.io/bbolt"
+	"golang.org/x/term"
 	yaml "gopkg.in/yaml.v2"
 
 	"github.com/twpayne/chezmoi/internal/chezmoi"
 	"github.com/twpayne/chezmoi/internal/git"
 )
 
-const commitMessageTemplateAsset = "assets/templates/COMMIT_MESSAGE.tmpl"
+const (
+	chezmoiRootName            = ".chezmoiroot"
+	commitMessageTemplateAsset = "assets/templates/COMMIT_MESSAGE.tmpl"
+)
 
 var whitespaceRegexp = regexp.MustCompile(`\s+`)
 
@@ -43,6 +47,17 @@ type sourceVCSConfig struct {
 	Pull       interface{}
 }
 
+type backupConfig struct {
+	Keep    int
+	MaxSize int64
+}
+
+type privilegedConfig struct {
+	Command string
+	Args    []string
+	Dirs    map[string]string
+}
+
 type templateConfig struct {
 	Options []string
 }
@@ -53,8 +68,10 @@ type Config struct {
 	err               error
 	fs                vfs.FS
 	mutator           chezmoi.Mutator
-	SourceDir         string
+	SourceDir         []string
+	WritableSourceDir string
 	DestDir           string
+	Mode              chezmoi.Mode
 	Umask             permValue
 	DryRun            bool
 	Follow            bool
@@ -62,8 +79,11 @@ type Config struct {
 	Verbose           bool
 	Color             string
 	Debug             bool
+	Backup            backupConfig
 	GPG               chezmoi.GPG
 	GPGRecipient      string
+	Interpreters      map[string]*chezmoi.Interpreter
+	Privileged        privilegedConfig
 	SourceVCS         sourceVCSConfig
 	Template          templateConfig
 	Merge             mergeConfig
@@ -82,7 +102,9 @@ type Config struct {
 	maxDiffDataSize   int
 	templateFuncs     template.FuncMap
 	add               addCmdConfig
+	apply             applyCmdConfig
 	archive           archiveCmdConfig
+	chattr            chattrCmdConfig
 	completion        completionCmdConfig
 	data              dataCmdConfig
 	dump              dumpCmdConfig
@@ -92,14 +114,21 @@ type Config struct {
 	init              initCmdConfig
 	keyring           keyringCmdConfig
 	managed           managedCmdConfig
+	merge             mergeCmdConfig
 	purge             purgeCmdConfig
+	reAdd             reAddCmdConfig
 	remove            removeCmdConfig
+	rollback          rollbackCmdConfig
+	status            statusCmdConfig
 	update            updateCmdConfig
 	upgrade           upgradeCmdConfig
 	Stdin             io.Reader
+	stdinReader       *bufio.Reader
 	Stdout            io.Writer
 	Stderr            io.Writer
 	bds               *xdg.BaseDirectorySpecification
+	backupBucket      []byte
+	entryStateBucket  []byte
 	scriptStateBucket []byte
 
 	//nolint:structcheck,unused
@@ -140,7 +169,15 @@ var (
 func newConfig(options ...configOption) *Config {
 	c := &Config{
 		Umask: permValue(chezmoi.GetUmask()),
+		Mode:  chezmoi.ModeFile,
 		Color: "auto",
+		Backup: backupConfig{
+			Keep:    10,
+			MaxSize: 1 * 1024 * 1024, // 1MB
+		},
+		Privileged: privilegedConfig{
+			Command: "sudo",
+		},
 		SourceVCS: sourceVCSConfig{
 			Command: "git",
 		},
@@ -158,6 +195,8 @@ func newConfig(options ...configOption) *Config {
 		},
 		maxDiffDataSize:   1 * 1024 * 1024, // 1MB
 		templateFuncs:     sprig.TxtFuncMap(),
+		backupBucket:      []byte("backup"),
+		entryStateBucket:  []byte("entryState"),
 		scriptStateBucket: []byte("script"),
 		Stdin:             os.Stdin,
 		Stdout:            os.Stdout,
@@ -179,36 +218,118 @@ func (c *Config) addTemplateFunc(key string, value interface{}) {
 	c.templateFuncs[key] = value
 }
 
+// applyArgsWithState applies args and maintains the persistent state of the
+// targets that it writes. It prompts for each change if the apply is
+// interactive, otherwise it refuses to overwrite targets that have been
+// modified since they were last applied. It records the state of every target
+// that it changes in a new backup set so that the changes can be rolled back,
+// and records the state of every target that it writes.
+func (c *Config) applyArgsWithState(args []string, persistentState chezmoi.PersistentState) (err error) {
+	ts, applyOptions, entries, err := c.getApplyEntries(args, persistentState)
+	if err != nil {
+		return err
+	}
+	allEntries := entries
+	if allEntries == nil {
+		allEntries = ts.AllEntries()
+	}
+	if !c.DryRun && c.Backup.Keep > 0 {
+		backupSet := chezmoi.NewBackupSet(time.Now())
+		c.mutator = chezmoi.NewBackupMutator(c.mutator, c.fs, backupSet, c.Backup.MaxSize)
+		defer func() {
+			for _, path := range backupSet.Skipped {
+				fmt.Fprintf(c.Stderr, "warning: %s: not backed up, file is larger than backup.maxSize\n", path)
+			}
+			if saveErr := backupSet.Save(persistentState, c.backupBucket); saveErr != nil && err == nil {
+				err = saveErr
+			}
+			if pruneErr := chezmoi.PruneBackupSets(persistentState, c.backupBucket, c.Backup.Keep); pruneErr != nil && err == nil {
+				err = pruneErr
+			}
+		}()
+	}
+	switch {
+	case c.apply.interactive:
+		// The user reviews every change, so there is no need to check for
+		// modified targets.
+		if err := c.promptApply(ts, applyOptions, entries); err != nil {
+			return err
+		}
+	case !c.DryRun && !c.apply.force:
+		if err := c.resolveDrift(ts, applyOptions, allEntries); err != nil {
+			return err
+		}
+	}
+	if err := c.applyEntries(ts, applyOptions, entries); err != nil {
+		return err
+	}
+	if c.DryRun {
+		return nil
+	}
+	return chezmoi.RecordEntryStates(c.fs, applyOptions, allEntries)
+}
+
 func (c *Config) applyArgs(args []string, persistentState chezmoi.PersistentState) error {
+	ts, applyOptions, entries, err := c.getApplyEntries(args, persistentState)
+	if err != nil {
+		return err
+	}
+	return c.applyEntries(ts, applyOptions, entries)
+}
+
+// topLevelEntries returns ts's top level entries.
+func topLevelEntries(ts *chezmoi.TargetState) []chezmoi.Entry {
+	entries := make([]chezmoi.Entry, 0, len(ts.Entries))
+	for _, entry := range ts.Entries {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// applyEntries applies entries, or all of ts if entries is nil.
+func (c *Config) applyEntries(ts *chezmoi.TargetState, applyOptions *chezmoi.ApplyOptions, entries []chezmoi.Entry) error {
 	fs := vfs.NewReadOnlyFS(c.fs)
+	if entries == nil {
+		return ts.Apply(fs, c.mutator, c.Follow, applyOptions)
+	}
+	return chezmoi.ApplyEntries(fs, c.mutator, c.Follow, applyOptions, entries)
+}
+
+// getApplyEntries returns the target state, the options for applying it, and
+// the entries for args. If args is empty then the returned entries are nil.
+func (c *Config) getApplyEntries(args []string, persistentState chezmoi.PersistentState) (*chezmoi.TargetState, *chezmoi.ApplyOptions, []chezmoi.Entry, error) {
 	ts, err := c.getTargetState(nil)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
+	}
+	scriptEnv, err := ts.ScriptEnv()
+	if err != nil {
+		return nil, nil, nil, err
 	}
 	applyOptions := &chezmoi.ApplyOptions{
 		DestDir:           ts.DestDir,
 		DryRun:            c.DryRun,
+		EntryStateBucket:  c.entryStateBucket,
 		Ignore:            ts.TargetIgnore.Match,
+		Interpreters:      ts.Interpreters,
+		Mode:              c.Mode,
 		PersistentState:   persistentState,
+		PrivilegedDirs:    ts.PrivilegedDirs,
 		Remove:            c.Remove,
+		ScriptEnv:         scriptEnv,
 		ScriptStateBucket: c.scriptStateBucket,
 		Stdout:            c.Stdout,
 		Umask:             ts.Umask,
 		Verbose:           c.Verbose,
 	}
 	if len(args) == 0 {
-		return ts.Apply(fs, c.mutator, c.Follow, applyOptions)
+		return ts, applyOptions, nil, nil
 	}
 	entries, err := c.getEntries(ts, args)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
-	for _, entry := range entries {
-		if err := entry.Apply(fs, c.mutator, c.Follow, applyOptions); err != nil {
-			return err
-		}
-	}
-	return nil
+	return ts, applyOptions, entries, nil
 }
 
 func (c *Config) autoCommit(vcs VCS) error {
@@ -216,10 +337,10 @@ func (c *Config) autoCommit(vcs VCS) error {
 	if addArgs == nil {
 		return fmt.Errorf("%s: autocommit not supported", c.SourceVCS.Command)
 	}
-	if err := c.run(c.SourceDir, c.SourceVCS.Command, addArgs...); err != nil {
+	if err := c.run(c.getSourceDir(), c.SourceVCS.Command, addArgs...); err != nil {
 		return err
 	}
-	output, err := c.output(c.SourceDir, c.SourceVCS.Command, vcs.StatusArgs()...)
+	output, err := c.output(c.getSourceDir(), c.SourceVCS.Command, vcs.StatusArgs()...)
 	if err != nil {
 		return err
 	}
@@ -243,7 +364,7 @@ func (c *Config) autoCommit(vcs VCS) error {
 		return err
 	}
 	commitArgs := vcs.CommitArgs(sb.String())
-	return c.run(c.SourceDir, c.SourceVCS.Command, commitArgs...)
+	return c.run(c.getSourceDir(), c.SourceVCS.Command, commitArgs...)
 }
 
 func (c *Config) autoCommitAndAutoPush(cmd *cobra.Command, args []string) error {
@@ -272,7 +393,28 @@ func (c *Config) autoPush(vcs VCS) error {
 	if pushArgs == nil {
 		return fmt.Errorf("%s: autopush not supported", c.SourceVCS.Command)
 	}
-	return c.run(c.SourceDir, c.SourceVCS.Command, pushArgs...)
+	return c.run(c.getSourceDir(), c.SourceVCS.Command, pushArgs...)
+}
+
+// copySourcePath copies the file or directory at sourcePath in another source
+// directory to path in the writable source directory.
+func (c *Config) copySourcePath(sourcePath, path string) error {
+	umask := os.FileMode(c.Umask)
+	info, err := c.fs.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return vfs.MkdirAll(c.mutator, path, 0o777&^umask)
+	}
+	if err := vfs.MkdirAll(c.mutator, filepath.Dir(path), 0o777&^umask); err != nil {
+		return err
+	}
+	data, err := c.fs.ReadFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	return c.mutator.WriteFile(path, data, 0o666&^umask, nil)
 }
 
 // ensureNoError ensures that no error was encountered when loading c.
@@ -284,26 +426,27 @@ func (c *Config) ensureNoError(cmd *cobra.Command, args []string) error {
 }
 
 func (c *Config) ensureSourceDirectory() error {
-	info, err := c.fs.Stat(c.SourceDir)
+	sourceDir := c.getSourceDir()
+	info, err := c.fs.Stat(sourceDir)
 	switch {
 	case err == nil && info.IsDir():
-		private, err := chezmoi.IsPrivate(c.fs, c.SourceDir, true)
+		private, err := chezmoi.IsPrivate(c.fs, sourceDir, true)
 		if err != nil {
 			return err
 		}
 		if !private {
-			if err := c.mutator.Chmod(c.SourceDir, 0o700&^os.FileMode(c.Umask)); err != nil {
+			if err := c.mutator.Chmod(sourceDir, 0o700&^os.FileMode(c.Umask)); err != nil {
 				return err
 			}
 		}
 		return nil
 	case os.IsNotExist(err):
-		if err := vfs.MkdirAll(c.mutator, filepath.Dir(c.SourceDir), 0o777&^os.FileMode(c.Umask)); err != nil {
+		if err := vfs.MkdirAll(c.mutator, filepath.Dir(sourceDir), 0o777&^os.FileMode(c.Umask)); err != nil {
 			return err
 		}
-		return c.mutator.Mkdir(c.SourceDir, 0o700&^os.FileMode(c.Umask))
+		return c.mutator.Mkdir(sourceDir, 0o700&^os.FileMode(c.Umask))
 	case err == nil:
-		return fmt.Errorf("%s: not a directory", c.SourceDir)
+		return fmt.Errorf("%s: not a directory", sourceDir)
 	default:
 		return err
 	}
@@ -324,10 +467,14 @@ func (c *Config) getData() (map[string]interface{}, error) {
 }
 
 func (c *Config) getDefaultData() (map[string]interface{}, error) {
+	sourceRootDir, err := c.getSourceRootDir(c.getSourceDir())
+	if err != nil {
+		return nil, err
+	}
 	data := map[string]interface{}{
 		"arch":      runtime.GOARCH,
 		"os":        runtime.GOOS,
-		"sourceDir": c.SourceDir,
+		"sourceDir": sourceRootDir,
 	}
 
 	// Determine the user's username and group, if possible.
@@ -434,6 +581,10 @@ func (c *Config) getEntries(ts *chezmoi.TargetState, args []string) ([]chezmoi.E
 	return entries, nil
 }
 
+func (c *Config) getCacheDir() string {
+	return filepath.Join(c.bds.CacheHome, "chezmoi")
+}
+
 func (c *Config) getPersistentState(options *bolt.Options) (chezmoi.PersistentState, error) {
 	persistentStateFile := c.getPersistentStateFile()
 	if options == nil {
@@ -449,7 +600,14 @@ func (c *Config) getPersistentState(options *bolt.Options) (chezmoi.PersistentSt
 	if errors.Is(err, bolt.ErrTimeout) {
 		return nil, fmt.Errorf("failed to lock database: %w", err)
 	}
-	return state, err
+	if err != nil {
+		return nil, err
+	}
+	if err := state.MigrateScriptState(c.scriptStateBucket); err != nil {
+		_ = state.Close()
+		return nil, err
+	}
+	return state, nil
 }
 
 func (c *Config) getPersistentStateFile() string {
@@ -468,6 +626,23 @@ func (c *Config) getPersistentStateFile() string {
 func (c *Config) getTargetState(populateOptions *chezmoi.PopulateOptions) (*chezmoi.TargetState, error) {
 	fs := vfs.NewReadOnlyFS(c.fs)
 
+	sourceDir := c.getSourceDir()
+	var sourceRootDir string
+	sourceRootDirs := make([]string, 0, len(c.SourceDir))
+	for _, dir := range c.SourceDir {
+		rootDir, err := c.getSourceRootDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		if dir == sourceDir {
+			sourceRootDir = rootDir
+		}
+		sourceRootDirs = append(sourceRootDirs, rootDir)
+	}
+	if sourceRootDir == "" {
+		return nil, fmt.Errorf("%s: not a source directory", sourceDir)
+	}
+
 	data, err := c.getData()
 	if err != nil {
 		return nil, err
@@ -481,15 +656,27 @@ func (c *Config) getTargetState(populateOptions *chezmoi.PopulateOptions) (*chez
 		}
 	}
 
+	privilegedDirs, err := c.getPrivilegedDirs()
+	if err != nil {
+		return nil, err
+	}
+
 	// For backwards compatibility, prioritize gpgRecipient over gpg.recipient.
 	if c.GPGRecipient != "" {
 		c.GPG.Recipient = c.GPGRecipient
 	}
 
 	ts := chezmoi.NewTargetState(
+		chezmoi.WithCacheDir(c.getCacheDir()),
+		chezmoi.WithCacheFS(c.fs),
 		chezmoi.WithDestDir(destDir),
+		chezmoi.WithDryRun(c.DryRun),
 		chezmoi.WithGPG(&c.GPG),
-		chezmoi.WithSourceDir(c.SourceDir),
+		chezmoi.WithInterpreters(c.Interpreters),
+		chezmoi.WithPrivilegedDirs(privilegedDirs),
+		chezmoi.WithRefreshExternals(c.apply.refreshExternals),
+		chezmoi.WithSourceDir(sourceRootDir),
+		chezmoi.WithSourceDirs(sourceRootDirs),
 		chezmoi.WithTemplateData(data),
 		chezmoi.WithTemplateFuncs(c.templateFuncs),
 		chezmoi.WithTemplateOptions(c.Template.Options),
@@ -504,6 +691,59 @@ func (c *Config) getTargetState(populateOptions *chezmoi.PopulateOptions) (*chez
 	return ts, nil
 }
 
+// getPrivilegedDirs returns the privileged directories, keyed by their
+// cleaned target names.
+func (c *Config) getPrivilegedDirs() (map[string]string, error) {
+	if len(c.Privileged.Dirs) == 0 {
+		return nil, nil
+	}
+	privilegedDirs := make(map[string]string, len(c.Privileged.Dirs))
+	for targetName, dir := range c.Privileged.Dirs {
+		cleanTargetName := filepath.Clean(filepath.FromSlash(targetName))
+		if filepath.IsAbs(cleanTargetName) || cleanTargetName == "." || cleanTargetName == ".." || strings.HasPrefix(cleanTargetName, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s: invalid privileged target name", targetName)
+		}
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("%s: privileged directory is not absolute", dir)
+		}
+		privilegedDirs[cleanTargetName] = filepath.Clean(dir)
+	}
+	return privilegedDirs, nil
+}
+
+// getSourceDir returns the writable source directory. This is
+// c.WritableSourceDir, if set, or the last source directory otherwise. add,
+// chattr, edit, forget, remove, and version control commands operate on the
+// writable source directory.
+func (c *Config) getSourceDir() string {
+	switch {
+	case c.WritableSourceDir != "":
+		return c.WritableSourceDir
+	case len(c.SourceDir) == 0:
+		return ""
+	default:
+		return c.SourceDir[len(c.SourceDir)-1]
+	}
+}
+
+// getSourceRootDir returns the root of the source state in sourceDir. This is
+// the subdirectory of sourceDir named in its .chezmoiroot file, if it has one,
+// or sourceDir itself otherwise.
+func (c *Config) getSourceRootDir(sourceDir string) (string, error) {
+	data, err := c.fs.ReadFile(filepath.Join(sourceDir, chezmoiRootName))
+	switch {
+	case os.IsNotExist(err):
+		return sourceDir, nil
+	case err != nil:
+		return "", err
+	}
+	root := filepath.Clean(filepath.FromSlash(strings.TrimSpace(string(data))))
+	if filepath.IsAbs(root) || root == ".." || strings.HasPrefix(root, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: %s: not a subdirectory of the source directory", filepath.Join(sourceDir, chezmoiRootName), root)
+	}
+	return filepath.Join(sourceDir, root), nil
+}
+
 func (c *Config) getVCS() (VCS, error) {
 	vcs, ok := vcses[filepath.Base(c.SourceVCS.Command)]
 	if !ok {
@@ -512,6 +752,15 @@ func (c *Config) getVCS() (VCS, error) {
 	return vcs, nil
 }
 
+// getWritableSourcePath returns the source path of entry, or an error if entry
+// was not read from the writable source directory.
+func (c *Config) getWritableSourcePath(ts *chezmoi.TargetState, entry chezmoi.Entry) (string, error) {
+	if ts.EntrySourceDir(entry) != ts.SourceDir {
+		return "", fmt.Errorf("%s: not in writable source directory %s", entry.TargetName(), c.getSourceDir())
+	}
+	return filepath.Join(ts.SourceDir, entry.SourceName()), nil
+}
+
 func (c *Config) output(dir, name string, argv ...string) ([]byte, error) {
 	cmd := exec.Command(name, argv...)
 	if dir != "" {
@@ -524,15 +773,22 @@ func (c *Config) output(dir, name string, argv ...string) ([]byte, error) {
 	return c.mutator.IdempotentCmdOutput(cmd)
 }
 
+// interactive returns true if c can prompt the user for input.
+func (c *Config) interactive() bool {
+	if stdin, ok := c.Stdin.(*os.File); ok {
+		return term.IsTerminal(int(stdin.Fd()))
+	}
+	return true
+}
+
 //nolint:unparam
 func (c *Config) prompt(s, choices string) (byte, error) {
-	r := bufio.NewReader(c.Stdin)
 	for {
 		_, err := fmt.Printf("%s [%s]? ", s, strings.Join(strings.Split(choices, ""), ","))
 		if err != nil {
 			return 0, err
 		}
-		line, err := r.ReadString('\n')
+		line, err := c.readLine()
 		if err != nil {
 			return 0, err
 		}
@@ -543,6 +799,15 @@ func (c *Config) prompt(s, choices string) (byte, error) {
 	}
 }
 
+// readLine reads a line from c.Stdin. c.Stdin is buffered once so that input
+// is not lost between consecutive reads.
+func (c *Config) readLine() (string, error) {
+	if c.stdinReader == nil {
+		c.stdinReader = bufio.NewReader(c.Stdin)
+	}
+	return c.stdinReader.ReadString('\n')
+}
+
 // run runs name argv... in dir.
 func (c *Config) run(dir, name string, argv ...string) error {
 	cmd := exec.Command(name, argv...)
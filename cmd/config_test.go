@@ -68,6 +68,39 @@ func TestAutoCommitCommitMessage(t *testing.T) {
 	}
 }
 
+func TestGetSourceDir(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		sourceDir         []string
+		writableSourceDir string
+		want              string
+	}{
+		{
+			name:      "single",
+			sourceDir: []string{"/home/user/.local/share/chezmoi"},
+			want:      "/home/user/.local/share/chezmoi",
+		},
+		{
+			name:      "last",
+			sourceDir: []string{"/team", "/home/user/.local/share/chezmoi"},
+			want:      "/home/user/.local/share/chezmoi",
+		},
+		{
+			name:              "writable",
+			sourceDir:         []string{"/team", "/home/user/.local/share/chezmoi"},
+			writableSourceDir: "/team",
+			want:              "/team",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newConfig()
+			c.SourceDir = tc.sourceDir
+			c.WritableSourceDir = tc.writableSourceDir
+			assert.Equal(t, tc.want, c.getSourceDir())
+		})
+	}
+}
+
 func TestUpperSnakeCaseToCamelCase(t *testing.T) {
 	for s, want := range map[string]string{
 		"BUG_REPORT_URL":   "bugReportURL",
@@ -149,6 +182,12 @@ func withAddCmdConfig(add addCmdConfig) configOption {
 	}
 }
 
+func withApplyCmdConfig(apply applyCmdConfig) configOption {
+	return func(c *Config) {
+		c.apply = apply
+	}
+}
+
 func withData(data map[string]interface{}) configOption {
 	return func(c *Config) {
 		c.Data = data
@@ -219,7 +258,7 @@ func withTestFS(fs vfs.FS) configOption {
 func withTestUser(username string) configOption {
 	return func(c *Config) {
 		homeDir := filepath.Join("/", "home", username)
-		c.SourceDir = filepath.Join(homeDir, ".local", "share", "chezmoi")
+		c.SourceDir = []string{filepath.Join(homeDir, ".local", "share", "chezmoi")}
 		c.DestDir = homeDir
 		c.Umask = 0o22
 		c.bds = &xdg.BaseDirectorySpecification{
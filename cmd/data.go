@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/twpayne/chezmoi/internal/chezmoi"
 )
 
 type dataCmdConfig struct {
@@ -33,9 +35,11 @@ func (c *Config) runDataCmd(cmd *cobra.Command, args []string) error {
 	if !ok {
 		return fmt.Errorf("%s: unknown format", c.data.format)
 	}
-	data, err := c.getData()
+	ts, err := c.getTargetState(&chezmoi.PopulateOptions{
+		ExecuteTemplates: false,
+	})
 	if err != nil {
 		return err
 	}
-	return format(c.Stdout, data)
+	return format(c.Stdout, ts.TemplateData)
 }
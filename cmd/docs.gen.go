@@ -1,4 +1,5 @@
 // Code generated by github.com/twpayne/chezmoi/internal/generate-assets. DO NOT EDIT.
+//go:build !noembeddocs
 // +build !noembeddocs
 
 package cmd
@@ -11,6 +12,7 @@ func init() {
 		"* [Upcoming](#upcoming)\n" +
 		"  * [Default diff format changing from `chezmoi` to `git`.](#default-diff-format-changing-from-chezmoi-to-git)\n" +
 		"  * [`gpgRecipient` config variable changing to `gpg.recipient`](#gpgrecipient-config-variable-changing-to-gpgrecipient)\n" +
+		"  * [`run_once_` scripts only run once](#run_once_-scripts-only-run-once)\n" +
 		"\n" +
 		"## Upcoming\n" +
 		"\n" +
@@ -33,6 +35,15 @@ func init() {
 		"      recipient = \"...\"\n" +
 		"\n" +
 		"Support for the `gpgRecipient` config variable will be removed in version 2.0.0.\n" +
+		"\n" +
+		"### `run_once_` scripts only run once\n" +
+		"\n" +
+		"Previously, scripts with the `run_once_` prefix were run again whenever their\n" +
+		"contents changed. They are now only ever run once. Scripts with the new\n" +
+		"`run_onchange_` prefix have the old behavior. Scripts that have already been\n" +
+		"run are recorded as such the first time chezmoi opens its state, so existing\n" +
+		"`run_once_` scripts will not be run again. To keep the old behavior, rename\n" +
+		"`run_once_` scripts to `run_onchange_`.\n" +
 		"\n")
 	assets["docs/CONTRIBUTING.md"] = []byte("" +
 		"# chezmoi Contributing Guide\n" +
@@ -330,13 +341,13 @@ func init() {
 		"have `.Brewfile` listing all the packages that you want installed and only want\n" +
 		"to run `brew bundle --global` when the contents of `.Brewfile` have changed.\n" +
 		"\n" +
-		"chezmoi has two types of scripts: scripts that run every time, and scripts that\n" +
-		"only run when their contents change. chezmoi does not have a mechanism to run a\n" +
+		"chezmoi has three types of scripts: scripts that run every time, scripts that\n" +
+		"only run once, and scripts that only run when their contents change. chezmoi does not have a mechanism to run a\n" +
 		"script when an arbitrary file has changed, but there are some ways to achieve\n" +
 		"the desired behavior:\n" +
 		"\n" +
 		"1. Have the script create `.Brewfile` instead of chezmoi, e.g. in your\n" +
-		"   `run_once_install-packages`:\n" +
+		"   `run_onchange_install-packages`:\n" +
 		"\n" +
 		"   ```sh\n" +
 		"   #!/bin/sh\n" +
@@ -350,7 +361,7 @@ func init() {
 		"   ```\n" +
 		"\n" +
 		"2. Don't use `.Brewfile`, and instead install the packages explicitly in\n" +
-		"   `run_once_install-packages`:\n" +
+		"   `run_onchange_install-packages`:\n" +
 		"\n" +
 		"   ```sh\n" +
 		"   #!/bin/sh\n" +
@@ -631,6 +642,7 @@ func init() {
 		"* [Pull the latest changes from your repo and apply them](#pull-the-latest-changes-from-your-repo-and-apply-them)\n" +
 		"* [Pull the latest changes from your repo and see what would change, without actually applying the changes](#pull-the-latest-changes-from-your-repo-and-see-what-would-change-without-actually-applying-the-changes)\n" +
 		"* [Automatically commit and push changes to your repo](#automatically-commit-and-push-changes-to-your-repo)\n" +
+		"* [Share a base source directory with your team](#share-a-base-source-directory-with-your-team)\n" +
 		"* [Use templates to manage files that vary from machine to machine](#use-templates-to-manage-files-that-vary-from-machine-to-machine)\n" +
 		"* [Use completely separate config files on different machines](#use-completely-separate-config-files-on-different-machines)\n" +
 		"  * [Without using symlinks](#without-using-symlinks)\n" +
@@ -740,6 +752,27 @@ func init() {
 		"accidentally add a secret in plain text, that secret will be pushed to your\n" +
 		"public repo.\n" +
 		"\n" +
+		"## Share a base source directory with your team\n" +
+		"\n" +
+		"`sourceDir` can be a list of source directories. chezmoi reads each source\n" +
+		"directory in turn, and targets in later source directories override targets in\n" +
+		"earlier ones. `.chezmoiignore` and `.chezmoiremove` patterns from all source\n" +
+		"directories are combined, and templates in `.chezmoitemplates` in later source\n" +
+		"directories override templates with the same name in earlier ones.\n" +
+		"\n" +
+		"For example, if your team's dotfiles are in `~/src/team-dotfiles` and your\n" +
+		"personal dotfiles are in `~/.local/share/chezmoi`, add the following to your\n" +
+		"config file:\n" +
+		"\n" +
+		"    sourceDir = [\"/home/user/src/team-dotfiles\", \"/home/user/.local/share/chezmoi\"]\n" +
+		"\n" +
+		"`chezmoi add`, `chezmoi chattr`, and `chezmoi edit` only write to the last\n" +
+		"source directory. Changing a target from an earlier source directory copies it\n" +
+		"to the last source directory, leaving the earlier source directory unchanged.\n" +
+		"To write to a different source directory, set `writableSourceDir`. Version\n" +
+		"control commands, like `chezmoi git` and `chezmoi update`, run in the writable\n" +
+		"source directory.\n" +
+		"\n" +
 		"## Use templates to manage files that vary from machine to machine\n" +
 		"\n" +
 		"The primary goal of chezmoi is to manage configuration files across multiple\n" +
@@ -1369,18 +1402,29 @@ func init() {
 		"### Understand how scripts work\n" +
 		"\n" +
 		"chezmoi supports scripts, which are executed when you run `chezmoi apply`. The\n" +
-		"scripts can either run every time you run `chezmoi apply`, or only when their\n" +
-		"contents have changed.\n" +
+		"scripts can either run every time you run `chezmoi apply`, only once, or only\n" +
+		"when their contents have changed.\n" +
 		"\n" +
 		"In verbose mode, the script's contents will be printed before executing it. In\n" +
 		"dry-run mode, the script is not executed.\n" +
 		"\n" +
 		"Scripts are any file in the source directory with the prefix `run_`, and are\n" +
-		"executed in alphabetical order. Scripts that should only be run when their\n" +
-		"contents change have the prefix `run_once_`.\n" +
+		"executed in alphabetical order. Scripts that should only be run once have the\n" +
+		"prefix `run_once_`. Scripts that should only be run when their contents change\n" +
+		"have the prefix `run_onchange_`.\n" +
+		"\n" +
+		"By default, scripts are run in the same order as other entries are applied, so\n" +
+		"a script runs after the entries that sort before it. Scripts with the prefix\n" +
+		"`run_before_` are run before any files, directories, or symlinks are updated\n" +
+		"and scripts with the prefix `run_after_` are run after all other entries have\n" +
+		"been updated. Within each phase, scripts are run in alphabetical order of their\n" +
+		"target paths across the whole source directory. The prefixes can be combined\n" +
+		"with `once_` or `onchange_`, for example\n" +
+		"`run_onchange_before_install-packages.sh`.\n" +
 		"\n" +
 		"Scripts break chezmoi's declarative approach, and as such should be used\n" +
-		"sparingly. Any script should be idempotent, even `run_once_` scripts.\n" +
+		"sparingly. Any script should be idempotent, even `run_once_` and\n" +
+		"`run_onchange_` scripts.\n" +
 		"\n" +
 		"Scripts must be created manually in the source directory, typically by running\n" +
 		"`chezmoi cd` and then creating a file with a `run_` prefix. Scripts are executed\n" +
@@ -1395,10 +1439,10 @@ func init() {
 		"### Install packages with scripts\n" +
 		"\n" +
 		"Change to the source directory and create a file called\n" +
-		"`run_once_install-packages.sh`:\n" +
+		"`run_onchange_install-packages.sh`:\n" +
 		"\n" +
 		"    chezmoi cd\n" +
-		"    $EDITOR run_once_install-packages.sh\n" +
+		"    $EDITOR run_onchange_install-packages.sh\n" +
 		"\n" +
 		"In this file create your package installation script, e.g.\n" +
 		"\n" +
@@ -1406,11 +1450,11 @@ func init() {
 		"    sudo apt install ripgrep\n" +
 		"\n" +
 		"The next time you run `chezmoi apply` or `chezmoi update` this script will be\n" +
-		"run. As it has the `run_once_` prefix, it will not be run again unless its\n" +
+		"run. As it has the `run_onchange_` prefix, it will not be run again unless its\n" +
 		"contents change, for example if you add more packages to be installed.\n" +
 		"\n" +
 		"This script can also be a template. For example, if you create\n" +
-		"`run_once_install-packages.sh.tmpl` with the contents:\n" +
+		"`run_onchange_install-packages.sh.tmpl` with the contents:\n" +
 		"\n" +
 		"    {{ if eq .chezmoi.os \"linux\" -}}\n" +
 		"    #!/bin/sh\n" +
@@ -1511,7 +1555,7 @@ func init() {
 		"\n" +
 		"Finally, modify any of your templates to use the `codespaces` variable if\n" +
 		"needed. For example, to install `vim-gtk` on Linux but not in Codespaces, your\n" +
-		"`run_once_install-packages.sh.tmpl` might contain:\n" +
+		"`run_onchange_install-packages.sh.tmpl` might contain:\n" +
 		"\n" +
 		"```\n" +
 		"{{- if (and (eq .chezmoi.os \"linux\")) (not .codespaces))) -}}\n" +
@@ -1868,12 +1912,18 @@ func init() {
 		"  * [`--version`](#--version)\n" +
 		"* [Configuration file](#configuration-file)\n" +
 		"  * [Variables](#variables)\n" +
+		"  * [Symlink mode](#symlink-mode)\n" +
+		"  * [Privileged directories](#privileged-directories)\n" +
 		"  * [Examples](#examples)\n" +
 		"* [Source state attributes](#source-state-attributes)\n" +
 		"* [Special files and directories](#special-files-and-directories)\n" +
 		"  * [`.chezmoi.<format>.tmpl`](#chezmoiformattmpl)\n" +
+		"  * [`.chezmoiattributes`](#chezmoiattributes)\n" +
+		"  * [`.chezmoidata.<format>`](#chezmoidataformat)\n" +
+		"  * [`.chezmoiexternal.<format>`](#chezmoiexternalformat)\n" +
 		"  * [`.chezmoiignore`](#chezmoiignore)\n" +
 		"  * [`.chezmoiremove`](#chezmoiremove)\n" +
+		"  * [`.chezmoiroot`](#chezmoiroot)\n" +
 		"  * [`.chezmoitemplates`](#chezmoitemplates)\n" +
 		"  * [`.chezmoiversion`](#chezmoiversion)\n" +
 		"* [Commands](#commands)\n" +
@@ -1902,11 +1952,14 @@ func init() {
 		"  * [`managed`](#managed)\n" +
 		"  * [`merge` *targets*](#merge-targets)\n" +
 		"  * [`purge`](#purge)\n" +
+		"  * [`re-add` [*targets*]](#re-add-targets)\n" +
 		"  * [`remove` *targets*](#remove-targets)\n" +
 		"  * [`rm` *targets*](#rm-targets)\n" +
+		"  * [`rollback`](#rollback)\n" +
 		"  * [`secret`](#secret)\n" +
 		"  * [`source` [*args*]](#source-args)\n" +
 		"  * [`source-path` [*targets*]](#source-path-targets)\n" +
+		"  * [`status` [*targets*]](#status-targets)\n" +
 		"  * [`unmanage` *targets*](#unmanage-targets)\n" +
 		"  * [`unmanaged`](#unmanaged)\n" +
 		"  * [`update`](#update)\n" +
@@ -2010,7 +2063,11 @@ func init() {
 		"\n" +
 		"### `-S`, `--source` *directory*\n" +
 		"\n" +
-		"Use *directory* as the source directory.\n" +
+		"Use *directory* as the source directory. This flag can be given multiple times\n" +
+		"to use multiple source directories. Commands that change the source state only\n" +
+		"change the writable source directory, which is set by `writableSourceDir` and\n" +
+		"is the last source directory by default. `forget` and `remove` fail for targets\n" +
+		"from other source directories.\n" +
 		"\n" +
 		"### `-v`, `--verbose`\n" +
 		"\n" +
@@ -2038,41 +2095,80 @@ func init() {
 		"\n" +
 		"The following configuration variables are available:\n" +
 		"\n" +
-		"| Section         | Variable     | Type     | Default value             | Description                                         |\n" +
-		"| --------------- | ------------ | -------- | ------------------------- | --------------------------------------------------- |\n" +
-		"| Top level       | `color`      | string   | `auto`                    | Colorize diffs                                      |\n" +
-		"|                 | `data`       | any      | *none*                    | Template data                                       |\n" +
-		"|                 | `destDir`    | string   | `~`                       | Destination directory                               |\n" +
-		"|                 | `dryRun`     | bool     | `false`                   | Dry run mode                                        |\n" +
-		"|                 | `follow`     | bool     | `false`                   | Follow symlinks                                     |\n" +
-		"|                 | `remove`     | bool     | `false`                   | Remove targets                                      |\n" +
-		"|                 | `sourceDir`  | string   | `~/.local/share/chezmoi`  | Source directory                                    |\n" +
-		"|                 | `umask`      | int      | *from system*             | Umask                                               |\n" +
-		"|                 | `verbose`    | bool     | `false`                   | Verbose mode                                        |\n" +
-		"| `bitwarden`     | `command`    | string   | `bw`                      | Bitwarden CLI command                               |\n" +
-		"| `cd`            | `args`       | []string | *none*                    | Extra args to shell in `cd` command                 |\n" +
-		"|                 | `command`    | string   | *none*                    | Shell to run in `cd` command                        |\n" +
-		"| `diff`          | `format`     | string   | `chezmoi`                 | Diff format, either `chezmoi` or `git`              |\n" +
-		"|                 | `pager`      | string   | *none*                    | Pager                                               |\n" +
-		"| `genericSecret` | `command`    | string   | *none*                    | Generic secret command                              |\n" +
-		"| `gopass`        | `command`    | string   | `gopass`                  | gopass CLI command                                  |\n" +
-		"| `gpg`           | `command`    | string   | `gpg`                     | GPG CLI command                                     |\n" +
-		"|                 | `recipient`  | string   | *none*                    | GPG recipient                                       |\n" +
-		"|                 | `symmetric`  | bool     | `false`                   | Use symmetric GPG encryption                        |\n" +
-		"| `keepassxc`     | `args`       | []string | *none*                    | Extra args to KeePassXC CLI command                 |\n" +
-		"|                 | `command`    | string   | `keepassxc-cli`           | KeePassXC CLI command                               |\n" +
-		"|                 | `database`   | string   | *none*                    | KeePassXC database                                  |\n" +
-		"| `lastpass`      | `command`    | string   | `lpass`                   | Lastpass CLI command                                |\n" +
-		"| `merge`         | `args`       | []string | *none*                    | Extra args to 3-way merge command                   |\n" +
-		"|                 | `command`    | string   | `vimdiff`                 | 3-way merge command                                 |\n" +
-		"| `onepassword`   | `cache`      | bool     | `true`                    | Enable optional caching provided by `op`            |\n" +
-		"|                 | `command`    | string   | `op`                      | 1Password CLI command                               |\n" +
-		"| `pass`          | `command`    | string   | `pass`                    | Pass CLI command                                    |\n" +
-		"| `sourceVCS`     | `autoCommit` | bool     | `false`                   | Commit changes to the source state after any change |\n" +
-		"|                 | `autoPush`   | bool     | `false`                   | Push changes to the source state after any change   |\n" +
-		"|                 | `command`    | string   | `git`                     | Source version control system                       |\n" +
-		"| `template`      | `options`    | []string | `[\"missingkey=error\"]`    | Template options                                    |\n" +
-		"| `vault`         | `command`    | string   | `vault`                   | Vault CLI command                                   |\n" +
+		"| Section         | Variable            | Type     | Default value            | Description                                         |\n" +
+		"| --------------- | ------------------- | -------- | ------------------------ | --------------------------------------------------- |\n" +
+		"| Top level       | `color`             | string   | `auto`                   | Colorize diffs                                      |\n" +
+		"|                 | `data`              | any      | *none*                   | Template data                                       |\n" +
+		"|                 | `destDir`           | string   | `~`                      | Destination directory                               |\n" +
+		"|                 | `dryRun`            | bool     | `false`                  | Dry run mode                                        |\n" +
+		"|                 | `follow`            | bool     | `false`                  | Follow symlinks                                     |\n" +
+		"|                 | `mode`              | string   | `file`                   | Mode, either `file` or `symlink`                    |\n" +
+		"|                 | `remove`            | bool     | `false`                  | Remove targets                                      |\n" +
+		"|                 | `sourceDir`         | []string | `~/.local/share/chezmoi` | Source directories                                  |\n" +
+		"|                 | `umask`             | int      | *from system*            | Umask                                               |\n" +
+		"|                 | `verbose`           | bool     | `false`                  | Verbose mode                                        |\n" +
+		"|                 | `writableSourceDir` | string   | *last source directory*  | Source directory for commands that change it        |\n" +
+		"| `backup`        | `keep`              | int      | `10`                     | Number of backup sets to keep for `rollback`        |\n" +
+		"|                 | `maxSize`           | int      | `1048576`                | Maximum size in bytes of files to back up           |\n" +
+		"| `bitwarden`     | `command`           | string   | `bw`                     | Bitwarden CLI command                               |\n" +
+		"| `cd`            | `args`              | []string | *none*                   | Extra args to shell in `cd` command                 |\n" +
+		"|                 | `command`           | string   | *none*                   | Shell to run in `cd` command                        |\n" +
+		"| `diff`          | `format`            | string   | `chezmoi`                | Diff format, either `chezmoi` or `git`              |\n" +
+		"|                 | `pager`             | string   | *none*                   | Pager                                               |\n" +
+		"| `genericSecret` | `command`           | string   | *none*                   | Generic secret command                              |\n" +
+		"| `gopass`        | `command`           | string   | `gopass`                 | gopass CLI command                                  |\n" +
+		"| `gpg`           | `command`           | string   | `gpg`                    | GPG CLI command                                     |\n" +
+		"|                 | `recipient`         | string   | *none*                   | GPG recipient                                       |\n" +
+		"|                 | `symmetric`         | bool     | `false`                  | Use symmetric GPG encryption                        |\n" +
+		"| `interpreters`  | *ext*               | object   | *none*                   | Interpreter for scripts with extension *ext*        |\n" +
+		"| `keepassxc`     | `args`              | []string | *none*                   | Extra args to KeePassXC CLI command                 |\n" +
+		"|                 | `command`           | string   | `keepassxc-cli`          | KeePassXC CLI command                               |\n" +
+		"|                 | `database`          | string   | *none*                   | KeePassXC database                                  |\n" +
+		"| `lastpass`      | `command`           | string   | `lpass`                  | Lastpass CLI command                                |\n" +
+		"| `merge`         | `args`              | []string | *none*                   | Extra args to 3-way merge command                   |\n" +
+		"|                 | `command`           | string   | `vimdiff`                | 3-way merge command                                 |\n" +
+		"| `onepassword`   | `cache`             | bool     | `true`                   | Enable optional caching provided by `op`            |\n" +
+		"|                 | `command`           | string   | `op`                     | 1Password CLI command                               |\n" +
+		"| `pass`          | `command`           | string   | `pass`                   | Pass CLI command                                    |\n" +
+		"| `privileged`    | `args`              | []string | *none*                   | Extra args to privilege escalation command          |\n" +
+		"|                 | `command`           | string   | `sudo`                   | Privilege escalation command                        |\n" +
+		"|                 | `dirs`              | object   | *none*                   | Privileged directories                              |\n" +
+		"| `sourceVCS`     | `autoCommit`        | bool     | `false`                  | Commit changes to the source state after any change |\n" +
+		"|                 | `autoPush`          | bool     | `false`                  | Push changes to the source state after any change   |\n" +
+		"|                 | `command`           | string   | `git`                    | Source version control system                       |\n" +
+		"| `template`      | `options`           | []string | `[\"missingkey=error\"]`   | Template options                                    |\n" +
+		"| `vault`         | `command`           | string   | `vault`                  | Vault CLI command                                   |\n" +
+		"\n" +
+		"### Symlink mode\n" +
+		"\n" +
+		"By default, chezmoi writes the contents of each target. If `mode` is `symlink`\n" +
+		"then chezmoi instead creates targets as symlinks to their files in the source\n" +
+		"directory, so edits to targets change the source state directly. Files that\n" +
+		"are templates, encrypted, executable, private, read-only, `create_`, or\n" +
+		"`modify_` files, or have an owner or group, cannot be symlinks and are still\n" +
+		"written as files, as are scripts. `diff` and `verify` report targets that are\n" +
+		"not yet symlinks.\n" +
+		"\n" +
+		"### Privileged directories\n" +
+		"\n" +
+		"chezmoi can also manage targets outside the destination directory that need\n" +
+		"elevated privileges to change, like files in `/etc`. `privileged.dirs` maps\n" +
+		"target paths in the source state to absolute directories. For example, with the\n" +
+		"following configuration, the source state's `etc/hosts` is applied to\n" +
+		"`/etc/hosts` instead of `~/etc/hosts`:\n" +
+		"\n" +
+		"```toml\n" +
+		"[privileged]\n" +
+		"    command = \"sudo\"\n" +
+		"[privileged.dirs]\n" +
+		"    \"etc/\" = \"/etc\"\n" +
+		"```\n" +
+		"\n" +
+		"Changes to targets in privileged directories are made by running `chmod`,\n" +
+		"`chown`, `install`, `ln`, `mkdir`, `mv`, and `rm` with `privileged.command`\n" +
+		"and `privileged.args`, for example `sudo` or `doas`, so you are only prompted\n" +
+		"for elevation when a privileged target actually changes. All other targets\n" +
+		"are changed directly. Scripts are never run with elevated privileges.\n" +
 		"\n" +
 		"### Examples\n" +
 		"\n" +
@@ -2115,31 +2211,120 @@ func init() {
 		"\n" +
 		"| Prefix       | Effect                                                                         |\n" +
 		"| ------------ | ------------------------------------------------------------------------------ |\n" +
+		"| `create_`    | Only create the file if it does not already exist.                             |\n" +
 		"| `encrypted_` | Encrypt the file in the source state.                                          |\n" +
 		"| `once_`      | Only run script once.                                                          |\n" +
+		"| `onchange_`  | Only run script when its contents change.                                      |\n" +
+		"| `before_`    | Run script before updating the destination directory.                          |\n" +
+		"| `after_`     | Run script after updating the destination directory.                           |\n" +
 		"| `private_`   | Remove all group and world permissions from the target file or directory.      |\n" +
+		"| `readonly_`  | Remove all write permissions from the target file or directory.                |\n" +
 		"| `empty_`     | Ensure the file exists, even if is empty. By default, empty files are removed. |\n" +
 		"| `exact_`     | Remove anything not managed by chezmoi.                                        |\n" +
 		"| `executable_`| Add executable permissions to the target file.                                 |\n" +
+		"| `modify_`    | Treat the contents as a script that modifies an existing file.                 |\n" +
 		"| `run_`       | Treat the contents as a script to run.                                         |\n" +
 		"| `symlink_`   | Create a symlink instead of a regular file.                                    |\n" +
+		"| `nametmpl_`  | Treat the rest of the name as a template.                                      |\n" +
 		"| `dot_`       | Rename to use a leading dot, e.g. `dot_foo` becomes `.foo`.                    |\n" +
+		"| `literal_`   | Stop parsing prefixes, e.g. `literal_dot_foo` becomes `dot_foo`.               |\n" +
+		"\n" +
+		"| Suffix     | Effect                                                              |\n" +
+		"| ---------- | ------------------------------------------------------------------- |\n" +
+		"| `.tmpl`    | Treat the contents of the source file as a template.                |\n" +
+		"| `.literal` | Stop parsing suffixes, e.g. `foo.tmpl.literal` becomes `foo.tmpl`.  |\n" +
+		"\n" +
+		"Order of prefixes is important, the order is `run_`, `create_`, `modify_`,\n" +
+		"`exact_`, `encrypted_`, `private_`, `readonly_`, `empty_`, `executable_`,\n" +
+		"`symlink_`, `once_` or `onchange_`, `before_` or `after_`, `nametmpl_`, `dot_`\n" +
+		"or `literal_`. Suffixes are parsed from the end of the name, so `.tmpl` comes\n" +
+		"after `.literal`.\n" +
 		"\n" +
-		"| Suffix  | Effect                                               |\n" +
-		"| ------- | ---------------------------------------------------- |\n" +
-		"| `.tmpl` | Treat the contents of the source file as a template. |\n" +
+		"`literal_` and `.literal` let you manage targets whose names would otherwise be\n" +
+		"interpreted as attributes. For example, the target `~/run_me.tmpl` has the\n" +
+		"source name `literal_run_me.tmpl.literal`. `chezmoi add` and `chezmoi chattr`\n" +
+		"add these escapes automatically when they are needed.\n" +
 		"\n" +
-		"Order of prefixes is important, the order is `run_`, `exact_`, `private_`,\n" +
-		"`empty_`, `executable_`, `symlink_`, `once_`, `dot_`.\n" +
+		"Attributes can also be set without changing source names with a\n" +
+		"[`.chezmoiattributes`](#chezmoiattributes) file.\n" +
 		"\n" +
 		"Different target types allow different prefixes and suffixes:\n" +
 		"\n" +
-		"| Target type   | Allowed prefixes                                          | Allowed suffixes |\n" +
-		"| ------------- | --------------------------------------------------------- | ---------------- |\n" +
-		"| Directory     | `exact_`, `private_`, `dot_`                              | *none*           |\n" +
-		"| Regular file  | `encrypted_`, `private_`, `empty_`, `executable_`, `dot_` | `.tmpl`          |\n" +
-		"| Script        | `run_`, `once_`                                           | `.tmpl`          |\n" +
-		"| Symbolic link | `symlink_`, `dot_`,                                       | `.tmpl`          |\n" +
+		"| Target type   | Allowed prefixes                                                                                           | Allowed suffixes    |\n" +
+		"| ------------- | ---------------------------------------------------------------------------------------------------------- | ------------------- |\n" +
+		"| Directory     | `exact_`, `private_`, `readonly_`, `nametmpl_`, `dot_`, `literal_`                                         | *none*              |\n" +
+		"| Regular file  | `encrypted_`, `private_`, `readonly_`, `empty_`, `executable_`, `nametmpl_`, `dot_`, `literal_`            | `.tmpl`, `.literal` |\n" +
+		"| Create file   | `create_`, `encrypted_`, `private_`, `readonly_`, `empty_`, `executable_`, `nametmpl_`, `dot_`, `literal_` | `.tmpl`, `.literal` |\n" +
+		"| Modify file   | `modify_`, `encrypted_`, `private_`, `readonly_`, `empty_`, `executable_`, `nametmpl_`, `dot_`, `literal_` | `.tmpl`, `.literal` |\n" +
+		"| Script        | `run_`, `encrypted_`, `once_` or `onchange_`, `before_` or `after_`, `nametmpl_`, `literal_`               | `.tmpl`, `.literal` |\n" +
+		"| Symbolic link | `symlink_`, `nametmpl_`, `dot_`, `literal_`                                                                | `.tmpl`, `.literal` |\n" +
+		"\n" +
+		"Files with the `modify_` prefix manage only part of a target file. The contents\n" +
+		"of the source file are executed as a script with the current contents of the\n" +
+		"target file on its standard input, or empty standard input if the target file\n" +
+		"does not exist. The standard output of the script becomes the new contents of\n" +
+		"the target file. The script is run in the target file's parent directory. If\n" +
+		"the source file is empty then the target file is left unchanged.\n" +
+		"\n" +
+		"Files and directories with the `readonly_` prefix have all write permissions\n" +
+		"removed from their targets. chezmoi temporarily makes read-only targets\n" +
+		"writable when it needs to update them or their contents. `chezmoi add` adds the\n" +
+		"`readonly_` prefix to targets that have no write permissions.\n" +
+		"\n" +
+		"Entries with the `nametmpl_` prefix have the rest of their name, after any\n" +
+		"`dot_` or `literal_` prefix is applied, executed as a template to compute their\n" +
+		"target name. This is useful for targets whose names differ between machines,\n" +
+		"for example `private_dot_ssh/config.d/nametmpl_{{ .chezmoi.hostname }}.conf`.\n" +
+		"Name templates have access to the same data as other templates. It is an error\n" +
+		"for a name template to render to an empty name or to a name containing a path\n" +
+		"separator, or for an entry with a name template to have the same target as\n" +
+		"another entry in the same source directory. `chezmoi add` keeps the name\n" +
+		"template of targets that are already in the source state.\n" +
+		"\n" +
+		"Files with the `create_` prefix are only written if the target does not\n" +
+		"already exist. If the target exists then neither its contents nor its\n" +
+		"permissions are changed.\n" +
+		"\n" +
+		"Scripts with the `encrypted_` prefix are stored encrypted in the source state\n" +
+		"and are decrypted before being executed. As with encrypted files, decryption\n" +
+		"happens before the contents are interpreted as a template.\n" +
+		"\n" +
+		"Scripts with the `once_` prefix are only run once. Scripts with the\n" +
+		"`onchange_` prefix are run again whenever their contents, after template\n" +
+		"execution, change.\n" +
+		"\n" +
+		"Scripts with the `before_` prefix are run before any other entries are applied\n" +
+		"and scripts with the `after_` prefix are run after all other entries have been\n" +
+		"applied. Within each phase, scripts are run in order of their target names. If\n" +
+		"a script's target directory does not yet exist then the script is run in the\n" +
+		"destination directory.\n" +
+		"\n" +
+		"Scripts, including `modify_` scripts, are executed directly, so they must\n" +
+		"include a shebang line or be executable binaries, unless an interpreter is\n" +
+		"configured for their file extension in the `interpreters` section of the config\n" +
+		"file. Each interpreter has a `command` and optional `args`, and the script's\n" +
+		"path is appended to the arguments. For example, to run `.py` scripts with\n" +
+		"`python3` and `.ps1` scripts with PowerShell:\n" +
+		"\n" +
+		"```toml\n" +
+		"[interpreters.py]\n" +
+		"    command = \"python3\"\n" +
+		"[interpreters.ps1]\n" +
+		"    command = \"powershell\"\n" +
+		"    args = [\"-NoLogo\", \"-File\"]\n" +
+		"```\n" +
+		"\n" +
+		"Scripts, including `modify_` scripts, are run with the following additional\n" +
+		"environment variables:\n" +
+		"\n" +
+		"| Variable              | Value                                      |\n" +
+		"| --------------------- | ------------------------------------------ |\n" +
+		"| `CHEZMOI_ARCH`        | The architecture, e.g. `amd64`             |\n" +
+		"| `CHEZMOI_DATA`        | The template data, encoded as JSON         |\n" +
+		"| `CHEZMOI_DEST_DIR`    | The destination directory                  |\n" +
+		"| `CHEZMOI_OS`          | The operating system, e.g. `linux`         |\n" +
+		"| `CHEZMOI_SOURCE_DIR`  | The source directory                       |\n" +
+		"| `CHEZMOI_TARGET_PATH` | The absolute path of the script's target   |\n" +
 		"\n" +
 		"## Special files and directories\n" +
 		"\n" +
@@ -2158,6 +2343,113 @@ func init() {
 		"    data:\n" +
 		"        email: \"{{ $email }}\"\n" +
 		"\n" +
+		"### `.chezmoiattributes`\n" +
+		"\n" +
+		"If a file called `.chezmoiattributes` exists in the source state then it is\n" +
+		"interpreted as a list of patterns and the attributes of the targets that match\n" +
+		"them, in the style of `.gitattributes`. Each line contains a pattern followed by\n" +
+		"one or more attributes separated by whitespace. An attribute sets the attribute,\n" +
+		"and an attribute prefixed with a minus sign (`-`) unsets it. The available\n" +
+		"attributes are `create`, `empty`, `encrypted`, `exact`, `executable`, `private`,\n" +
+		"`readonly`, and `template`.\n" +
+		"\n" +
+		"Attributes of the form `owner=`*owner* and `group=`*group* set the owner and\n" +
+		"group of the target, and can be either names or numeric ids. An empty value\n" +
+		"unsets the owner or group. Targets without an owner or group are owned by the\n" +
+		"user running chezmoi. Changing the owner usually requires running chezmoi as\n" +
+		"root. The owner and group are also included in the output of the\n" +
+		"[`archive`](#archive) command.\n" +
+		"\n" +
+		"Patterns are matched against target paths relative to the directory containing\n" +
+		"the `.chezmoiattributes` file. As in `.gitattributes`, a pattern without a slash\n" +
+		"matches targets with that name at any depth, and a pattern with a leading slash\n" +
+		"only matches relative to the directory. Whitespace, `#`, and pattern\n" +
+		"metacharacters in patterns can be escaped with a backslash (`\\`), and `chattr\n" +
+		"--attributes-file` escapes them in the target names that it writes. Attributes\n" +
+		"set in `.chezmoiattributes` files take precedence over attributes encoded in\n" +
+		"source file names. If more than one line matches a target then later lines take\n" +
+		"precedence over earlier ones, and `.chezmoiattributes` files in subdirectories\n" +
+		"take precedence over those in their parent directories. `.chezmoiattributes` is\n" +
+		"interpreted as a template.\n" +
+		"\n" +
+		"#### `.chezmoiattributes` examples\n" +
+		"\n" +
+		"    .ssh         private exact\n" +
+		"    .ssh/id_*    private\n" +
+		"    bin/*        executable\n" +
+		"    .gitconfig   template\n" +
+		"    .profile     -template\n" +
+		"    .ssh/config  owner=root group=wheel\n" +
+		"\n" +
+		"### `.chezmoidata.<format>`\n" +
+		"\n" +
+		"If a file called `.chezmoidata.<format>` exists in the source state then it is\n" +
+		"interpreted as template data in the given format. *format* must be one of\n" +
+		"`json`, `toml`, or `yaml`. Files in a directory called `.chezmoidata` are read\n" +
+		"in the same way. `.chezmoidata` files and directories can be anywhere in the\n" +
+		"source state.\n" +
+		"\n" +
+		"All `.chezmoidata` files are read in lexical order and deeply merged before any\n" +
+		"template is executed, with later files overriding earlier ones. Data in the\n" +
+		"config file takes precedence over data in `.chezmoidata` files.\n" +
+		"\n" +
+		"#### `.chezmoidata.<format>` examples\n" +
+		"\n" +
+		"    [git]\n" +
+		"        editor = \"vim\"\n" +
+		"        pager = \"less\"\n" +
+		"\n" +
+		"### `.chezmoiexternal.<format>`\n" +
+		"\n" +
+		"If a file called `.chezmoiexternal.<format>` exists in the source state then it\n" +
+		"is interpreted as a list of targets to populate from URLs. *format* must be one\n" +
+		"of `json`, `toml`, or `yaml`. `.chezmoiexternal.<format>` is interpreted as a\n" +
+		"template. Target paths are relative to the directory containing the\n" +
+		"`.chezmoiexternal.<format>` file, and any missing parent directories are\n" +
+		"created.\n" +
+		"\n" +
+		"Each target has the following fields:\n" +
+		"\n" +
+		"| Field             | Type     | Default value | Description                                        |\n" +
+		"| ----------------- | -------- | ------------- | -------------------------------------------------- |\n" +
+		"| `type`            | string   | *none*        | Type of external, `file`, `archive`, or `git-repo` |\n" +
+		"| `url`             | string   | *none*        | URL, either `file://`, `http://`, or `https://`    |\n" +
+		"| `executable`      | bool     | `false`       | Make a `file` target executable                    |\n" +
+		"| `exact`           | bool     | `false`       | Remove anything not in an `archive` target         |\n" +
+		"| `format`          | string   | *from URL*    | Archive format                                     |\n" +
+		"| `stripComponents` | int      | `0`           | Number of leading path components to strip         |\n" +
+		"| `include`         | []string | *all*         | Patterns of archive members to include             |\n" +
+		"| `exclude`         | []string | *none*        | Patterns of archive members to exclude             |\n" +
+		"| `refreshPeriod`   | duration | `0`           | Period after which the URL is downloaded again     |\n" +
+		"\n" +
+		"`file` targets are populated with the contents of the URL. `archive` targets are\n" +
+		"directories populated with the contents of an archive, which can be a `tar`,\n" +
+		"`tar.gz`, `tgz`, `tar.bz2`, `tbz2`, or `zip` file. Leading `./` components of\n" +
+		"archive member names are ignored. Include and exclude patterns are matched\n" +
+		"against member names after any leading components have been stripped.\n" +
+		"`git-repo` targets are cloned with `git clone` if they do not already exist,\n" +
+		"and updated with `git pull --ff-only` when they are refreshed.\n" +
+		"\n" +
+		"Downloaded URLs are cached in `~/.cache/chezmoi`. A refresh period of `0` means\n" +
+		"that cached downloads never expire. Use the `--refresh-externals` flag to the\n" +
+		"`apply` command to download all URLs again. The cache is not updated when\n" +
+		"`--dry-run` is set.\n" +
+		"\n" +
+		"#### `.chezmoiexternal.<format>` examples\n" +
+		"\n" +
+		"    [\".oh-my-zsh\"]\n" +
+		"        type = \"archive\"\n" +
+		"        url = \"https://github.com/ohmyzsh/ohmyzsh/archive/master.tar.gz\"\n" +
+		"        exact = true\n" +
+		"        stripComponents = 1\n" +
+		"        refreshPeriod = \"168h\"\n" +
+		"    [\".vim/autoload/plug.vim\"]\n" +
+		"        type = \"file\"\n" +
+		"        url = \"https://raw.githubusercontent.com/junegunn/vim-plug/master/plug.vim\"\n" +
+		"    [\".vim/pack/plugins/start/vim-airline\"]\n" +
+		"        type = \"git-repo\"\n" +
+		"        url = \"https://github.com/vim-airline/vim-airline.git\"\n" +
+		"\n" +
 		"### `.chezmoiignore`\n" +
 		"\n" +
 		"If a file called `.chezmoiignore` exists in the source state then it is\n" +
@@ -2199,6 +2491,21 @@ func init() {
 		"interpreted as a list of targets to remove. `.chezmoiremove` is interpreted as a\n" +
 		"template.\n" +
 		"\n" +
+		"### `.chezmoiroot`\n" +
+		"\n" +
+		"If a file called `.chezmoiroot` exists in the root of the source directory then\n" +
+		"its contents are interpreted as the path of a subdirectory of the source\n" +
+		"directory, relative to the source directory, which is used as the root of the\n" +
+		"source state. This allows the source directory to contain files, like a\n" +
+		"`README.md` or CI configuration, that are not part of the source state.\n" +
+		"Commands that operate on the source state, like `add`, `apply`, `chattr`,\n" +
+		"`edit`, and `source-path`, use the subdirectory. Version control commands, like\n" +
+		"`git` and `update`, still run in the source directory.\n" +
+		"\n" +
+		"#### `.chezmoiroot` examples\n" +
+		"\n" +
+		"    home\n" +
+		"\n" +
 		"### `.chezmoitemplates`\n" +
 		"\n" +
 		"If a directory called `.chezmoitemplates` exists, then all files in this\n" +
@@ -2245,6 +2552,10 @@ func init() {
 		"the `data` section of the config file. Longer substitutions occur before shorter\n" +
 		"ones. This implies the `--template` option.\n" +
 		"\n" +
+		"#### `--create`\n" +
+		"\n" +
+		"Set the `create` attribute on added files.\n" +
+		"\n" +
 		"#### `-e`, `--empty`\n" +
 		"\n" +
 		"Set the `empty` attribute on added files.\n" +
@@ -2281,16 +2592,86 @@ func init() {
 		"Ensure that *targets* are in the target state, updating them if necessary. If no\n" +
 		"targets are specified, the state of all targets are ensured.\n" +
 		"\n" +
+		"chezmoi records the contents of every file that it writes in its persistent\n" +
+		"state. If a file has been modified since chezmoi last wrote it and `apply`\n" +
+		"would overwrite it, then `apply` prompts you to overwrite it, skip it, merge it\n" +
+		"with the [`merge`](#merge-targets) command, or show the diff. If the standard\n" +
+		"input is not a terminal then `apply` instead fails, listing the modified files.\n" +
+		"`create_` and `modify_` files never overwrite changes and are not checked. The\n" +
+		"`apply` command accepts additional flags:\n" +
+		"\n" +
+		"#### `--export-script` *file*\n" +
+		"\n" +
+		"With `--dry-run`, write a POSIX shell script to *file* that makes the changes\n" +
+		"that `apply` would make instead of making them, for example to apply the\n" +
+		"target state on a machine that cannot run chezmoi. If *file* is `-` then the\n" +
+		"script is written to the standard output. The script contains the contents of\n" +
+		"every file, which it writes with here documents or `printf`, and the commands\n" +
+		"to set permissions, create directories and symlinks, remove targets, and run\n" +
+		"scripts. Paths in the script are absolute paths in the destination directory.\n" +
+		"Scripts that run once or on change are only included if they would run on this\n" +
+		"machine. Scripts are written to a temporary directory, keeping their file\n" +
+		"extension, and run with their configured interpreter and with the same\n" +
+		"`CHEZMOI_*` environment variables that `apply` would set.\n" +
+		"\n" +
+		"#### `--force`\n" +
+		"\n" +
+		"Overwrite files that have been modified since chezmoi last wrote them without\n" +
+		"prompting.\n" +
+		"\n" +
+		"#### `--format` *format*\n" +
+		"\n" +
+		"With `--dry-run`, print the plan of what `apply` would do as a list in\n" +
+		"*format*, either `json` or `yaml`, instead of doing it. Each operation has an\n" +
+		"`op` and a `path`, and, depending on the operation, the following fields:\n" +
+		"\n" +
+		"| Op             | Fields                                         |\n" +
+		"| -------------- | ---------------------------------------------- |\n" +
+		"| `chmod`        | `perm`                                         |\n" +
+		"| `chown`        | `owner`                                        |\n" +
+		"| `mkdir`        | `perm`                                         |\n" +
+		"| `removeAll`    |                                                |\n" +
+		"| `rename`       | `oldPath`                                      |\n" +
+		"| `runScript`    | `contentsSHA256`                               |\n" +
+		"| `writeFile`    | `perm`, `oldContentsSHA256`, `contentsSHA256`  |\n" +
+		"| `writeSymlink` | `linkname`                                     |\n" +
+		"\n" +
+		"Operations are listed in the order that `apply` would make them. If `path`\n" +
+		"already exists and is not a symlink then `oldPerm` is its current permissions,\n" +
+		"and `oldContentsSHA256` is only set if `path` is an existing file. Permissions are integers and contents are identified by their hex-encoded\n" +
+		"SHA256 sums.\n" +
+		"\n" +
+		"#### `-i`, `--interactive`\n" +
+		"\n" +
+		"For each target that would change, show the diff, in the format set by the\n" +
+		"`diff.format` configuration variable, and prompt to apply the change, skip it,\n" +
+		"merge it with the [`merge`](#merge-targets) command, or quit. Removals of\n" +
+		"targets in [`.chezmoiremove`](#chezmoiremove) and the contents of scripts are\n" +
+		"shown and prompted for in the same way. Skipping a directory skips everything\n" +
+		"in it, and quitting skips all remaining changes while still making the changes\n" +
+		"that you have already accepted. As you review every change, files that have\n" +
+		"been modified since chezmoi last wrote them are not checked separately.\n" +
+		"\n" +
+		"#### `--refresh-externals`\n" +
+		"\n" +
+		"Download all externals again, regardless of their refresh periods.\n" +
+		"\n" +
 		"#### `apply` examples\n" +
 		"\n" +
 		"    chezmoi apply\n" +
 		"    chezmoi apply --dry-run --verbose\n" +
 		"    chezmoi apply ~/.bashrc\n" +
+		"    chezmoi apply --force\n" +
+		"    chezmoi apply --dry-run --format=json\n" +
+		"    chezmoi apply --dry-run --export-script apply.sh\n" +
+		"    chezmoi apply --interactive\n" +
+		"    chezmoi apply --refresh-externals\n" +
 		"\n" +
 		"### `archive`\n" +
 		"\n" +
 		"Generate a tar archive of the target state. This can be piped into `tar` to\n" +
-		"inspect the target state.\n" +
+		"inspect the target state. The owner and group of targets set in\n" +
+		"[`.chezmoiattributes`](#chezmoiattributes) files are included in the archive.\n" +
 		"\n" +
 		"#### `--output`, `-o` *filename*\n" +
 		"\n" +
@@ -2303,9 +2684,10 @@ func init() {
 		"\n" +
 		"### `cat` *targets*\n" +
 		"\n" +
-		"Write the target state of *targets*  to stdout. *targets* must be files or\n" +
-		"symlinks. For files, the target file contents are written. For symlinks, the\n" +
-		"target target is written.\n" +
+		"Write the target state of *targets*  to stdout. *targets* must be files,\n" +
+		"scripts, or symlinks. For files, the target file contents are written. For\n" +
+		"scripts, the script contents are written. For symlinks, the target target is\n" +
+		"written.\n" +
 		"\n" +
 		"#### `cat` examples\n" +
 		"\n" +
@@ -2332,21 +2714,30 @@ func init() {
 		"\n" +
 		"| Attribute    | Abbreviation |\n" +
 		"| ------------ | ------------ |\n" +
+		"| `create`     | *none*       |\n" +
 		"| `empty`      | `e`          |\n" +
 		"| `encrypted`  | *none*       |\n" +
 		"| `exact`      | *none*       |\n" +
 		"| `executable` | `x`          |\n" +
 		"| `private`    | `p`          |\n" +
+		"| `readonly`   | `r`          |\n" +
 		"| `template`   | `t`          |\n" +
 		"\n" +
 		"Multiple attributes modifications may be specified by separating them with a\n" +
 		"comma (`,`).\n" +
 		"\n" +
+		"#### `--attributes-file`\n" +
+		"\n" +
+		"Write the attributes to the `.chezmoiattributes` file in the source directory\n" +
+		"instead of renaming the targets' source files.\n" +
+		"\n" +
 		"#### `chattr` examples\n" +
 		"\n" +
 		"    chezmoi chattr template ~/.bashrc\n" +
 		"    chezmoi chattr noempty ~/.profile\n" +
 		"    chezmoi chattr private,template ~/.netrc\n" +
+		"    chezmoi chattr readonly ~/.config/generated.conf\n" +
+		"    chezmoi chattr --attributes-file executable ~/bin/hello\n" +
 		"\n" +
 		"### `completion` *shell*\n" +
 		"\n" +
@@ -2364,8 +2755,9 @@ func init() {
 		"\n" +
 		"### `data`\n" +
 		"\n" +
-		"Write the computed template data in JSON format to stdout. The `data` command\n" +
-		"accepts additional flags:\n" +
+		"Write the computed template data in JSON format to stdout. The computed\n" +
+		"template data includes the data in `.chezmoidata.<format>` files and the config\n" +
+		"file. The `data` command accepts additional flags:\n" +
 		"\n" +
 		"#### `-f`, `--format` *format*\n" +
 		"\n" +
@@ -2447,7 +2839,9 @@ func init() {
 		"\n" +
 		"### `edit` [*targets*]\n" +
 		"\n" +
-		"Edit the source state of *targets*, which must be files or symlinks. If no\n" +
+		"Edit the source state of *targets*, which must be files, scripts, or symlinks.\n" +
+		"Encrypted files and scripts are decrypted before editing and re-encrypted\n" +
+		"afterwards. If no\n" +
 		"targets are given the the source directory itself is opened with `$EDITOR`. The\n" +
 		"`edit` command accepts additional arguments:\n" +
 		"\n" +
@@ -2640,9 +3034,17 @@ func init() {
 		"example if source is a template containing errors or an encrypted file that\n" +
 		"cannot be decrypted) a two-way merge is performed instead.\n" +
 		"\n" +
+		"#### `--patch-templates`\n" +
+		"\n" +
+		"Before merging a target that is generated by a template, apply the changes\n" +
+		"between its target state and its destination state to the template, as `re-add\n" +
+		"--patch-templates` does. The merge tool is only invoked if the changes cannot\n" +
+		"be applied.\n" +
+		"\n" +
 		"#### `merge` examples\n" +
 		"\n" +
 		"    chezmoi merge ~/.bashrc\n" +
+		"    chezmoi merge --patch-templates ~/.gitconfig\n" +
 		"\n" +
 		"### `purge`\n" +
 		"\n" +
@@ -2658,6 +3060,33 @@ func init() {
 		"    chezmoi purge\n" +
 		"    chezmoi purge --force\n" +
 		"\n" +
+		"### `re-add` [*targets*]\n" +
+		"\n" +
+		"Update the source state of each of *targets* whose contents in the destination\n" +
+		"directory differ from its target state, for example after editing it directly.\n" +
+		"If no targets are specified, all modified files are re-added. Encrypted files\n" +
+		"are encrypted again. Source files keep their names, so attributes like\n" +
+		"`private_` and `executable_` are unchanged, as are their permissions. Files\n" +
+		"generated by templates are skipped with a warning listing them, unless\n" +
+		"`--patch-templates` is given. `modify_` files and files from externals are\n" +
+		"always skipped.\n" +
+		"\n" +
+		"#### `--patch-templates`\n" +
+		"\n" +
+		"Update templates by applying the changes between each file's target state and\n" +
+		"its destination state to the template that generates it. Changes can only be\n" +
+		"applied to lines that are copied literally from the template. If a change\n" +
+		"touches lines that are generated by template actions, or if the template's\n" +
+		"output has changed since it was last applied, then the template is left\n" +
+		"unchanged, a three-way report of the template, its output, and the edited\n" +
+		"lines is printed for each conflicting change, and `re-add` exits with an error.\n" +
+		"\n" +
+		"#### `re-add` examples\n" +
+		"\n" +
+		"    chezmoi re-add\n" +
+		"    chezmoi re-add ~/.bashrc\n" +
+		"    chezmoi re-add --patch-templates ~/.gitconfig\n" +
+		"\n" +
 		"### `remove` *targets*\n" +
 		"\n" +
 		"Remove *targets* from both the source state and the destination directory.\n" +
@@ -2670,6 +3099,38 @@ func init() {
 		"\n" +
 		"`rm` is an alias for `remove`.\n" +
 		"\n" +
+		"### `rollback`\n" +
+		"\n" +
+		"Undo the changes made to targets by the last `apply`. Before `apply`, `init\n" +
+		"--apply`, or `update` changes or removes a target, chezmoi records the target's\n" +
+		"previous contents, permissions, and type in a backup set in its persistent\n" +
+		"state. `rollback` restores the targets in a backup set, removes any targets that\n" +
+		"the apply created, keeping directories that are no longer empty with a warning,\n" +
+		"and then deletes the backup set, so running `rollback` again undoes the apply\n" +
+		"before it. The number of backup sets kept is set by the `backup.keep`\n" +
+		"configuration variable, and setting it to `0` disables backups. Applies that do\n" +
+		"not change any targets do not create backup sets. Targets restored by `rollback`\n" +
+		"are not treated as modified by the next `apply`. Files larger than\n" +
+		"`backup.maxSize` bytes are not backed up, so `rollback` cannot restore them, and\n" +
+		"chezmoi prints a warning when it changes them. Setting `backup.maxSize` to `0`\n" +
+		"backs up files of any size.\n" +
+		"\n" +
+		"#### `--apply-id` *id*\n" +
+		"\n" +
+		"Roll back the apply with *id* instead of the last apply.\n" +
+		"\n" +
+		"#### `-l`, `--list`\n" +
+		"\n" +
+		"List the ids of the applies that can be rolled back, oldest first, with the\n" +
+		"time that they were made and the number of targets that they changed.\n" +
+		"\n" +
+		"#### `rollback` examples\n" +
+		"\n" +
+		"    chezmoi rollback\n" +
+		"    chezmoi rollback --list\n" +
+		"    chezmoi rollback --apply-id=20200102T030405.000000000Z\n" +
+		"    chezmoi rollback --dry-run --verbose\n" +
+		"\n" +
 		"### `secret`\n" +
 		"\n" +
 		"Run a secret manager's CLI, passing any extra arguments to the secret manager's\n" +
@@ -2709,13 +3170,42 @@ func init() {
 		"### `source-path` [*targets*]\n" +
 		"\n" +
 		"Print the path to each target's source state. If no targets are specified then\n" +
-		"print the source directory.\n" +
+		"print the root of the source state, which is the source directory unless it\n" +
+		"contains a `.chezmoiroot` file.\n" +
 		"\n" +
 		"#### `source-path` examples\n" +
 		"\n" +
 		"    chezmoi source-path\n" +
 		"    chezmoi source-path ~/.bashrc\n" +
 		"\n" +
+		"### `status` [*targets*]\n" +
+		"\n" +
+		"Print the status of each target that has changed since it was last applied or\n" +
+		"that would be changed by `apply`, like `git status --short`. If no targets are\n" +
+		"specified, the status of all targets is printed. Each line contains two status\n" +
+		"codes followed by the target's path, relative to the destination directory.\n" +
+		"The first code is the change to the destination state since chezmoi last wrote\n" +
+		"the file and the second code is the change that `apply` would make:\n" +
+		"\n" +
+		"| Code | Meaning         |\n" +
+		"| ---- | --------------- |\n" +
+		"| ` `  | No change       |\n" +
+		"| `A`  | Added           |\n" +
+		"| `D`  | Deleted         |\n" +
+		"| `M`  | Modified        |\n" +
+		"| `R`  | Script will run |\n" +
+		"\n" +
+		"#### `-f`, `--format` *format*\n" +
+		"\n" +
+		"Print the statuses as a list in *format*, either `json` or `yaml`, instead.\n" +
+		"Each status has the fields `path`, `sinceApply`, and `toApply`.\n" +
+		"\n" +
+		"#### `status` examples\n" +
+		"\n" +
+		"    chezmoi status\n" +
+		"    chezmoi status ~/.bashrc\n" +
+		"    chezmoi status --format=json\n" +
+		"\n" +
 		"### `unmanage` *targets*\n" +
 		"\n" +
 		"`unmanage` is an alias for `forget` for symmetry with `manage`.\n" +
@@ -2828,7 +3318,7 @@ func init() {
 		"| `.chezmoi.kernel`       | Contains information from `/proc/sys/kernel`. Linux only, useful for detecting specific kernels (i.e. Microsoft's WSL kernel).  |\n" +
 		"| `.chezmoi.os`           | Operating system, e.g. `darwin`, `linux`, etc. as returned by [runtime.GOOS](https://pkg.go.dev/runtime?tab=doc#pkg-constants). |\n" +
 		"| `.chezmoi.osRelease`    | The information from `/etc/os-release`, Linux only, run `chezmoi data` to see its output.                                       |\n" +
-		"| `.chezmoi.sourceDir`    | The source directory.                                                                                                           |\n" +
+		"| `.chezmoi.sourceDir`    | The root of the source state.                                                                                                   |\n" +
 		"| `.chezmoi.username`     | The username of the user running chezmoi.                                                                                       |\n" +
 		"\n" +
 		"Additional variables can be defined in the config file in the `data` section.\n" +
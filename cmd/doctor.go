@@ -122,11 +122,11 @@ func (c *Config) runDoctorCmd(cmd *cobra.Command, args []string) error {
 		&doctorRuntimeCheck{},
 		&doctorDirectoryCheck{
 			name:         "source directory",
-			path:         c.SourceDir,
+			path:         c.getSourceDir(),
 			dontWantPerm: 0o77,
 		},
 		&doctorSuspiciousFilesCheck{
-			path: c.SourceDir,
+			path: c.getSourceDir(),
 			filenames: map[string]bool{
 				".chezmoignore": true,
 			},
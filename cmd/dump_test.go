@@ -37,14 +37,18 @@ func TestDumpCmd(t *testing.T) {
 			"targetPath": "dir",
 			"exact":      false,
 			"perm":       float64(0o755),
+			"readonly":   false,
 			"entries": []interface{}{
 				map[string]interface{}{
 					"type":       "file",
 					"sourcePath": filepath.Join("/", "home", "user", ".local", "share", "chezmoi", "dir", "file"),
 					"targetPath": filepath.Join("dir", "file"),
+					"create":     false,
 					"empty":      false,
 					"encrypted":  false,
+					"modify":     false,
 					"perm":       float64(0o644),
+					"readonly":   false,
 					"template":   false,
 					"contents":   "contents",
 				},
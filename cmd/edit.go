@@ -39,9 +39,15 @@ func init() {
 	markRemainingZshCompPositionalArgumentsAsFiles(editCmd, 1)
 }
 
+// A contentsEntry is an entry with contents, i.e. a file or a script.
+type contentsEntry interface {
+	chezmoi.Entry
+	Contents() ([]byte, error)
+}
+
 type encryptedFile struct {
 	index          int
-	file           *chezmoi.File
+	entry          contentsEntry
 	ciphertextPath string
 	plaintextPath  string
 }
@@ -57,7 +63,11 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 		if c.edit.prompt {
 			cmd.Printf("warning: --prompt is currently ignored when edit is run with no arguments\n")
 		}
-		return c.runEditor(c.SourceDir)
+		sourceRootDir, err := c.getSourceRootDir(c.getSourceDir())
+		if err != nil {
+			return err
+		}
+		return c.runEditor(sourceRootDir)
 	}
 
 	if c.edit.prompt {
@@ -77,23 +87,36 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	// Build a list of source file names to pass to the editor. Check that each
-	// is either a file or a symlink. If the entry is an encrypted file then
-	// remember it.
+	// is either a file, a script, or a symlink. If the entry is an encrypted
+	// file or script then remember it.
 	argv := make([]string, len(entries))
 	var encryptedFiles []encryptedFile
 	for i, entry := range entries {
-		argv[i] = filepath.Join(c.SourceDir, entry.SourceName())
-		if file, ok := entry.(*chezmoi.File); ok {
-			if file.Encrypted {
-				ef := encryptedFile{
-					index:          i,
-					file:           file,
-					ciphertextPath: argv[i],
-				}
-				encryptedFiles = append(encryptedFiles, ef)
+		encrypted := false
+		switch entry := entry.(type) {
+		case *chezmoi.File:
+			encrypted = entry.Encrypted
+		case *chezmoi.Script:
+			encrypted = entry.Encrypted
+		case *chezmoi.Symlink:
+		default:
+			return fmt.Errorf("%s: not a file, script, or symlink", args[i])
+		}
+		// Edit entries in other source directories by overriding them in
+		// the writable source directory.
+		argv[i] = filepath.Join(ts.SourceDir, entry.SourceName())
+		if sourcePath := filepath.Join(ts.EntrySourceDir(entry), entry.SourceName()); sourcePath != argv[i] {
+			if err := c.copySourcePath(sourcePath, argv[i]); err != nil {
+				return err
 			}
-		} else if _, ok := entry.(*chezmoi.Symlink); !ok {
-			return fmt.Errorf("%s: not a file or symlink", args[i])
+		}
+		if encrypted {
+			ef := encryptedFile{
+				index:          i,
+				entry:          entry.(contentsEntry),
+				ciphertextPath: argv[i],
+			}
+			encryptedFiles = append(encryptedFiles, ef)
 		}
 	}
 
@@ -108,11 +131,11 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 		defer os.RemoveAll(tempDir)
 		for i := range encryptedFiles {
 			ef := &encryptedFiles[i]
-			plaintext, err := ef.file.Contents()
+			plaintext, err := ef.entry.Contents()
 			if err != nil {
 				return err
 			}
-			ef.plaintextPath = filepath.Join(tempDir, ef.file.SourceName())
+			ef.plaintextPath = filepath.Join(tempDir, ef.entry.SourceName())
 			if err := os.MkdirAll(filepath.Dir(ef.plaintextPath), 0o700&^os.FileMode(c.Umask)); err != nil {
 				return err
 			}
@@ -153,23 +176,43 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	persistentState, err := c.getPersistentState(nil)
+	if err != nil {
+		return err
+	}
+	defer persistentState.Close()
+
+	scriptEnv, err := ts.ScriptEnv()
+	if err != nil {
+		return err
+	}
+
 	readOnlyFS := vfs.NewReadOnlyFS(c.fs)
 	applyOptions := chezmoi.ApplyOptions{
 		DestDir:           ts.DestDir,
 		DryRun:            c.DryRun,
+		EntryStateBucket:  c.entryStateBucket,
 		Ignore:            ts.TargetIgnore.Match,
+		Interpreters:      ts.Interpreters,
+		Mode:              c.Mode,
+		PersistentState:   persistentState,
+		PrivilegedDirs:    ts.PrivilegedDirs,
+		ScriptEnv:         scriptEnv,
 		ScriptStateBucket: c.scriptStateBucket,
 		Stdout:            c.Stdout,
 		Umask:             ts.Umask,
 		Verbose:           c.Verbose,
 	}
+	// Never run scripts when checking whether an entry would be changed.
+	dryRunApplyOptions := applyOptions
+	dryRunApplyOptions.DryRun = true
 	for i, entry := range entries {
 		anyMutator := chezmoi.NewAnyMutator(chezmoi.NullMutator{})
 		var mutator chezmoi.Mutator = anyMutator
 		if c.edit.diff {
 			mutator = chezmoi.NewVerboseMutator(c.Stdout, mutator, c.colored, c.maxDiffDataSize)
 		}
-		if err := entry.Apply(readOnlyFS, mutator, c.Follow, &applyOptions); err != nil {
+		if err := entry.Apply(readOnlyFS, mutator, c.Follow, &dryRunApplyOptions); err != nil {
 			return err
 		}
 		if c.edit.apply && anyMutator.Mutated() {
@@ -191,6 +234,11 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 			if err := entry.Apply(readOnlyFS, c.mutator, c.Follow, &applyOptions); err != nil {
 				return err
 			}
+			if !c.DryRun {
+				if err := chezmoi.RecordEntryStates(c.fs, &applyOptions, []chezmoi.Entry{entry}); err != nil {
+					return err
+				}
+			}
 		}
 	}
 	return nil
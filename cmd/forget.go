@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"path/filepath"
-
 	"github.com/spf13/cobra"
 )
 
@@ -34,7 +32,11 @@ func (c *Config) runForgetCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	for _, entry := range entries {
-		if err := c.mutator.RemoveAll(filepath.Join(c.SourceDir, entry.SourceName())); err != nil {
+		sourcePath, err := c.getWritableSourcePath(ts, entry)
+		if err != nil {
+			return err
+		}
+		if err := c.mutator.RemoveAll(sourcePath); err != nil {
 			return err
 		}
 	}
@@ -21,6 +21,10 @@ var helps = map[string]help{
 			"  from the `data` section of the config file. Longer substitutions occur\n" +
 			"  before shorter ones. This implies the `--template` option.\n" +
 			"\n" +
+			"  `--create`\n" +
+			"\n" +
+			"  Set the `create` attribute on added files.\n" +
+			"\n" +
 			"  `-e`, `--empty`\n" +
 			"\n" +
 			"  Set the `empty` attribute on added files.\n" +
@@ -55,17 +59,90 @@ var helps = map[string]help{
 		long: "" +
 			"Description:\n" +
 			"  Ensure that *targets* are in the target state, updating them if necessary.\n" +
-			"  If no targets are specified, the state of all targets are ensured.",
+			"  If no targets are specified, the state of all targets are ensured.\n" +
+			"\n" +
+			"  chezmoi records the contents of every file that it writes in its persistent\n" +
+			"  state. If a file has been modified since chezmoi last wrote it and `apply`\n" +
+			"  would overwrite it, then `apply` prompts you to overwrite it, skip it, merge\n" +
+			"  it with the merge command, or show the diff. If the standard input is not a\n" +
+			"  terminal then `apply` instead fails, listing the modified files. `create_`\n" +
+			"  and `modify_` files never overwrite changes and are not checked. The `apply`\n" +
+			"  command accepts additional flags:\n" +
+			"\n" +
+			"  `--export-script` *file*\n" +
+			"\n" +
+			"  With `--dry-run`, write a POSIX shell script to *file* that makes the changes\n" +
+			"  that `apply` would make instead of making them, for example to apply the\n" +
+			"  target state on a machine that cannot run chezmoi. If *file* is `-` then the\n" +
+			"  script is written to the standard output. The script contains the contents\n" +
+			"  of every file, which it writes with here documents or `printf`, and the\n" +
+			"  commands to set permissions, create directories and symlinks, remove\n" +
+			"  targets, and run scripts. Paths in the script are absolute paths in the\n" +
+			"  destination directory. Scripts that run once or on change are only included\n" +
+			"  if they would run on this machine. Scripts are written to a temporary\n" +
+			"  directory, keeping their file extension, and run with their configured\n" +
+			"  interpreter and with the same `CHEZMOI_*` environment variables that `apply`\n" +
+			"  would set.\n" +
+			"\n" +
+			"  `--force`\n" +
+			"\n" +
+			"  Overwrite files that have been modified since chezmoi last wrote them\n" +
+			"  without prompting.\n" +
+			"\n" +
+			"  `--format` *format*\n" +
+			"\n" +
+			"  With `--dry-run`, print the plan of what `apply` would do as a list in\n" +
+			"  *format*, either `json` or `yaml`, instead of doing it. Each operation has\n" +
+			"  an `op` and a `path`, and, depending on the operation, the following fields:\n" +
+			"\n" +
+			"         OP      |             FIELDS\n" +
+			"  ---------------+---------------------------------\n" +
+			"    chmod        | perm\n" +
+			"    chown        | owner\n" +
+			"    mkdir        | perm\n" +
+			"    removeAll    |\n" +
+			"    rename       | oldPath\n" +
+			"    runScript    | contentsSHA256\n" +
+			"    writeFile    | perm, oldContentsSHA256,\n" +
+			"                 | contentsSHA256\n" +
+			"    writeSymlink | linkname\n" +
+			"\n" +
+			"  Operations are listed in the order that `apply` would make them. If `path`\n" +
+			"  already exists and is not a symlink then `oldPerm` is its current\n" +
+			"  permissions, and `oldContentsSHA256` is only set if `path` is an existing\n" +
+			"  file. Permissions are integers and contents are identified by their hex-\n" +
+			"  encoded SHA256 sums.\n" +
+			"\n" +
+			"  `-i`, `--interactive`\n" +
+			"\n" +
+			"  For each target that would change, show the diff, in the format set by the\n" +
+			"  `diff.format` configuration variable, and prompt to apply the change, skip\n" +
+			"  it, merge it with the merge command, or quit. Removals of targets in\n" +
+			"  .chezmoiremove and the contents of scripts are shown and prompted for in the\n" +
+			"  same way. Skipping a directory skips everything in it, and quitting skips\n" +
+			"  all remaining changes while still making the changes that you have already\n" +
+			"  accepted. As you review every change, files that have been modified since\n" +
+			"  chezmoi last wrote them are not checked separately.\n" +
+			"\n" +
+			"  `--refresh-externals`\n" +
+			"\n" +
+			"  Download all externals again, regardless of their refresh periods.",
 		example: "" +
 			"    chezmoi apply\n" +
 			"    chezmoi apply --dry-run --verbose\n" +
-			"    chezmoi apply ~/.bashrc",
+			"    chezmoi apply ~/.bashrc\n" +
+			"    chezmoi apply --force\n" +
+			"    chezmoi apply --dry-run --format=json\n" +
+			"    chezmoi apply --dry-run --export-script apply.sh\n" +
+			"    chezmoi apply --interactive\n" +
+			"    chezmoi apply --refresh-externals",
 	},
 	"archive": {
 		long: "" +
 			"Description:\n" +
 			"  Generate a tar archive of the target state. This can be piped into `tar` to\n" +
-			"  inspect the target state.\n" +
+			"  inspect the target state. The owner and group of targets set in\n" +
+			"  .chezmoiattributes files are included in the archive.\n" +
 			"\n" +
 			"  `--output`, `-o` *filename*\n" +
 			"\n" +
@@ -77,9 +154,10 @@ var helps = map[string]help{
 	"cat": {
 		long: "" +
 			"Description:\n" +
-			"  Write the target state of *targets*  to stdout. *targets* must be files or\n" +
-			"  symlinks. For files, the target file contents are written. For symlinks, the\n" +
-			"  target target is written.",
+			"  Write the target state of *targets*  to stdout. *targets* must be files,\n" +
+			"  scripts, or symlinks. For files, the target file contents are written. For\n" +
+			"  scripts, the script contents are written. For symlinks, the target target is\n" +
+			"  written.",
 		example: "" +
 			"    chezmoi cat ~/.bashrc",
 	},
@@ -104,19 +182,28 @@ var helps = map[string]help{
 			"\n" +
 			"    ATTRIBUTE  | ABBREVIATION\n" +
 			"  -------------+---------------\n" +
+			"    create     | none\n" +
 			"    empty      | e\n" +
 			"    encrypted  | none\n" +
 			"    exact      | none\n" +
 			"    executable | x\n" +
 			"    private    | p\n" +
+			"    readonly   | r\n" +
 			"    template   | t\n" +
 			"\n" +
 			"  Multiple attributes modifications may be specified by separating them with a\n" +
-			"  comma (`,`).",
+			"  comma (`,`).\n" +
+			"\n" +
+			"  `--attributes-file`\n" +
+			"\n" +
+			"  Write the attributes to the `.chezmoiattributes` file in the source\n" +
+			"  directory instead of renaming the targets' source files.",
 		example: "" +
 			"    chezmoi chattr template ~/.bashrc\n" +
 			"    chezmoi chattr noempty ~/.profile\n" +
-			"    chezmoi chattr private,template ~/.netrc",
+			"    chezmoi chattr private,template ~/.netrc\n" +
+			"    chezmoi chattr readonly ~/.config/generated.conf\n" +
+			"    chezmoi chattr --attributes-file executable ~/bin/hello",
 	},
 	"completion": {
 		long: "" +
@@ -134,8 +221,9 @@ var helps = map[string]help{
 	"data": {
 		long: "" +
 			"Description:\n" +
-			"  Write the computed template data in JSON format to stdout. The `data`\n" +
-			"  command accepts additional flags:\n" +
+			"  Write the computed template data in JSON format to stdout. The computed\n" +
+			"  template data includes the data in `.chezmoidata.<format>` files and the\n" +
+			"  config file. The `data` command accepts additional flags:\n" +
 			"\n" +
 			"  `-f`, `--format` *format*\n" +
 			"\n" +
@@ -213,9 +301,11 @@ var helps = map[string]help{
 	"edit": {
 		long: "" +
 			"Description:\n" +
-			"  Edit the source state of *targets*, which must be files or symlinks. If no\n" +
-			"  targets are given the the source directory itself is opened with `$EDITOR`.\n" +
-			"  The `edit` command accepts additional arguments:\n" +
+			"  Edit the source state of *targets*, which must be files, scripts, or\n" +
+			"  symlinks. Encrypted files and scripts are decrypted before editing and re-\n" +
+			"  encrypted afterwards. If no targets are given the the source directory\n" +
+			"  itself is opened with `$EDITOR`. The `edit` command accepts additional\n" +
+			"  arguments:\n" +
 			"\n" +
 			"  `-a`, `--apply`\n" +
 			"\n" +
@@ -407,9 +497,17 @@ var helps = map[string]help{
 			"  specified the merge tool is invoked for each target. If the target state\n" +
 			"  cannot be computed (for example if source is a template containing errors or\n" +
 			"  an encrypted file that cannot be decrypted) a two-way merge is performed\n" +
-			"  instead.",
+			"  instead.\n" +
+			"\n" +
+			"  `--patch-templates`\n" +
+			"\n" +
+			"  Before merging a target that is generated by a template, apply the changes\n" +
+			"  between its target state and its destination state to the template, as `re-\n" +
+			"  add --patch-templates` does. The merge tool is only invoked if the changes\n" +
+			"  cannot be applied.",
 		example: "" +
-			"    chezmoi merge ~/.bashrc",
+			"    chezmoi merge ~/.bashrc\n" +
+			"    chezmoi merge --patch-templates ~/.gitconfig",
 	},
 	"purge": {
 		long: "" +
@@ -424,6 +522,35 @@ var helps = map[string]help{
 			"    chezmoi purge\n" +
 			"    chezmoi purge --force",
 	},
+	"re-add": {
+		long: "" +
+			"Description:\n" +
+			"  Update the source state of each of *targets* whose contents in the\n" +
+			"  destination directory differ from its target state, for example after\n" +
+			"  editing it directly. If no targets are specified, all modified files are re-\n" +
+			"  added. Encrypted files are encrypted again. Source files keep their names,\n" +
+			"  so attributes like `private_` and `executable_` are unchanged, as are their\n" +
+			"  permissions. Files generated by templates are skipped with a warning listing\n" +
+			"  them, unless `--patch-templates` is given. `modify_` files and files from\n" +
+			"  externals are always skipped.\n" +
+			"\n" +
+			"  `--patch-templates`\n" +
+			"\n" +
+			"  Update templates by applying the changes between each file's target state\n" +
+			"  and its destination state to the template that generates it. Changes can\n" +
+			"  only be applied to lines that are copied literally from the template. If a\n" +
+			"  change touches lines that are generated by template actions, or if the\n" +
+			"  template's output has changed since it was last applied, then the template\n" +
+			"  is left unchanged, a three-way report of the template, its output, and the\n" +
+			"  edited lines is printed for each conflicting change, and `re-add` exits with\n" +
+			"  an error.\n" +
+			"\n" +
+			"  `re-add` examples\n" +
+			"\n" +
+			"    chezmoi re-add\n" +
+			"    chezmoi re-add ~/.bashrc\n" +
+			"    chezmoi re-add --patch-templates ~/.gitconfig",
+	},
 	"remove": {
 		long: "" +
 			"Description:\n" +
@@ -438,6 +565,37 @@ var helps = map[string]help{
 			"Description:\n" +
 			"  `rm` is an alias for `remove`.",
 	},
+	"rollback": {
+		long: "" +
+			"Description:\n" +
+			"  Undo the changes made to targets by the last `apply`. Before `apply`, `init --\n" +
+			"  apply`, or `update` changes or removes a target, chezmoi records the\n" +
+			"  target's previous contents, permissions, and type in a backup set in its\n" +
+			"  persistent state. `rollback` restores the targets in a backup set, removes\n" +
+			"  any targets that the apply created, keeping directories that are no longer\n" +
+			"  empty with a warning, and then deletes the backup set, so running `rollback`\n" +
+			"  again undoes the apply before it. The number of backup sets kept is set by\n" +
+			"  the `backup.keep` configuration variable, and setting it to `0` disables\n" +
+			"  backups. Applies that do not change any targets do not create backup sets.\n" +
+			"  Targets restored by `rollback` are not treated as modified by the next\n" +
+			"  `apply`. Files larger than `backup.maxSize` bytes are not backed up, so\n" +
+			"  `rollback` cannot restore them, and chezmoi prints a warning when it changes\n" +
+			"  them. Setting `backup.maxSize` to `0` backs up files of any size.\n" +
+			"\n" +
+			"  `--apply-id` *id*\n" +
+			"\n" +
+			"  Roll back the apply with *id* instead of the last apply.\n" +
+			"\n" +
+			"  `-l`, `--list`\n" +
+			"\n" +
+			"  List the ids of the applies that can be rolled back, oldest first, with the\n" +
+			"  time that they were made and the number of targets that they changed.",
+		example: "" +
+			"    chezmoi rollback\n" +
+			"    chezmoi rollback --list\n" +
+			"    chezmoi rollback --apply-id=20200102T030405.000000000Z\n" +
+			"    chezmoi rollback --dry-run --verbose",
+	},
 	"secret": {
 		long: "" +
 			"Description:\n" +
@@ -477,13 +635,42 @@ var helps = map[string]help{
 		long: "" +
 			"Description:\n" +
 			"  Print the path to each target's source state. If no targets are specified\n" +
-			"  then print the source directory.\n" +
+			"  then print the root of the source state, which is the source directory\n" +
+			"  unless it contains a `.chezmoiroot` file.\n" +
 			"\n" +
 			"  `source-path` examples\n" +
 			"\n" +
 			"    chezmoi source-path\n" +
 			"    chezmoi source-path ~/.bashrc",
 	},
+	"status": {
+		long: "" +
+			"Description:\n" +
+			"  Print the status of each target that has changed since it was last applied\n" +
+			"  or that would be changed by `apply`, like `git status --short`. If no targets\n" +
+			"  are specified, the status of all targets is printed. Each line contains two\n" +
+			"  status codes followed by the target's path, relative to the destination\n" +
+			"  directory. The first code is the change to the destination state since\n" +
+			"  chezmoi last wrote the file and the second code is the change that `apply`\n" +
+			"  would make:\n" +
+			"\n" +
+			"    CODE |     MEANING\n" +
+			"  -------+------------------\n" +
+			"         | No change\n" +
+			"    A    | Added\n" +
+			"    D    | Deleted\n" +
+			"    M    | Modified\n" +
+			"    R    | Script will run\n" +
+			"\n" +
+			"  `-f`, `--format` *format*\n" +
+			"\n" +
+			"  Print the statuses as a list in *format*, either `json` or `yaml`, instead.\n" +
+			"  Each status has the fields `path`, `sinceApply`, and `toApply`.",
+		example: "" +
+			"    chezmoi status\n" +
+			"    chezmoi status ~/.bashrc\n" +
+			"    chezmoi status --format=json",
+	},
 	"unmanage": {
 		long: "" +
 			"Description:\n" +
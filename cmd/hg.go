@@ -23,5 +23,5 @@ func (c *Config) runHgCmd(cmd *cobra.Command, args []string) error {
 	if trimExecutableSuffix(filepath.Base(c.SourceVCS.Command)) == "hg" {
 		name = c.SourceVCS.Command
 	}
-	return c.run(c.SourceDir, name, args...)
+	return c.run(c.getSourceDir(), name, args...)
 }
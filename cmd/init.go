@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"os"
@@ -48,12 +47,13 @@ func (c *Config) runInitCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	rawSourceDir, err := c.fs.RawPath(c.SourceDir)
+	sourceDir := c.getSourceDir()
+	rawSourceDir, err := c.fs.RawPath(sourceDir)
 	if err != nil {
 		return err
 	}
 
-	initialized, err := vcs.Initialized(c.SourceDir)
+	initialized, err := vcs.Initialized(sourceDir)
 	if err != nil {
 		return err
 	}
@@ -73,7 +73,7 @@ func (c *Config) runInitCmd(cmd *cobra.Command, args []string) error {
 			} else {
 				initArgs = vcs.InitArgs()
 			}
-			if err := c.run(c.SourceDir, c.SourceVCS.Command, initArgs...); err != nil {
+			if err := c.run(sourceDir, c.SourceVCS.Command, initArgs...); err != nil {
 				return err
 			}
 		case 1: // clone
@@ -86,12 +86,12 @@ func (c *Config) runInitCmd(cmd *cobra.Command, args []string) error {
 			}
 			// FIXME this should be part of VCS
 			if filepath.Base(c.SourceVCS.Command) == "git" {
-				if _, err := c.fs.Stat(filepath.Join(c.SourceDir, ".gitmodules")); err == nil {
+				if _, err := c.fs.Stat(filepath.Join(sourceDir, ".gitmodules")); err == nil {
 					for _, args := range [][]string{
 						{"submodule", "init"},
 						{"submodule", "update"},
 					} {
-						if err := c.run(c.SourceDir, c.SourceVCS.Command, args...); err != nil {
+						if err := c.run(sourceDir, c.SourceVCS.Command, args...); err != nil {
 							return err
 						}
 					}
@@ -109,7 +109,7 @@ func (c *Config) runInitCmd(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
-		if err := c.applyArgs(nil, persistentState); err != nil {
+		if err := c.applyArgsWithState(nil, persistentState); err != nil {
 			return err
 		}
 	}
@@ -175,7 +175,7 @@ func (c *Config) createConfigFile() error {
 
 func (c *Config) findConfigTemplate() (string, string, string, error) {
 	for _, ext := range viper.SupportedExts {
-		contents, err := c.fs.ReadFile(filepath.Join(c.SourceDir, ".chezmoi."+ext+chezmoi.TemplateSuffix))
+		contents, err := c.fs.ReadFile(filepath.Join(c.getSourceDir(), ".chezmoi."+ext+chezmoi.TemplateSuffix))
 		switch {
 		case os.IsNotExist(err):
 			continue
@@ -205,7 +205,7 @@ func (c *Config) promptInt(field string) int64 {
 
 func (c *Config) promptString(field string) string {
 	fmt.Fprintf(c.Stdout, "%s? ", field)
-	value, err := bufio.NewReader(c.Stdin).ReadString('\n')
+	value, err := c.readLine()
 	panicOnError(err)
 	return strings.TrimSpace(value)
 }
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"path/filepath"
 	"sort"
 
 	"github.com/spf13/cobra"
@@ -76,7 +75,7 @@ func (c *Config) runManagedCmd(cmd *cobra.Command, args []string) error {
 		if ts.TargetIgnore.Match(targetName) {
 			continue
 		}
-		fmt.Fprintln(c.Stdout, filepath.Join(ts.DestDir, targetName))
+		fmt.Fprintln(c.Stdout, ts.TargetPath(targetName))
 	}
 
 	return nil
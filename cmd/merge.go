@@ -26,9 +26,16 @@ type mergeConfig struct {
 	Args    []string
 }
 
+type mergeCmdConfig struct {
+	patchTemplates bool
+}
+
 func init() {
 	rootCmd.AddCommand(mergeCmd)
 
+	persistentFlags := mergeCmd.PersistentFlags()
+	persistentFlags.BoolVar(&config.merge.patchTemplates, "patch-templates", false, "patch templates with changes to the files they generate before merging")
+
 	markRemainingZshCompPositionalArgumentsAsFiles(mergeCmd, 1)
 }
 
@@ -52,8 +59,35 @@ func (c *Config) runMergeCmd(cmd *cobra.Command, args []string) error {
 	}
 	defer os.RemoveAll(tempDir)
 
+	var persistentState chezmoi.PersistentState
+	if c.merge.patchTemplates {
+		persistentState, err = c.getPersistentState(nil)
+		if err != nil {
+			return err
+		}
+		defer persistentState.Close()
+	}
+
 	for i, entry := range entries {
-		if err := c.runMergeCommand(cmd, args[i], entry, tempDir); err != nil {
+		// Only run the merge command if changes to the target cannot be
+		// patched into its template.
+		if file, ok := entry.(*chezmoi.File); ok && file.Template && c.merge.patchTemplates {
+			contents, err := c.fs.ReadFile(ts.TargetPath(file.TargetName()))
+			switch {
+			case chezmoi.IsNotExist(err):
+			case err != nil:
+				return err
+			default:
+				patched, err := c.patchTemplateSource(ts, persistentState, file, contents)
+				if err != nil {
+					return err
+				}
+				if patched {
+					continue
+				}
+			}
+		}
+		if err := c.runMergeCommand(ts, args[i], entry, tempDir); err != nil {
 			return err
 		}
 	}
@@ -61,7 +95,7 @@ func (c *Config) runMergeCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func (c *Config) runMergeCommand(cmd *cobra.Command, arg string, entry chezmoi.Entry, tempDir string) error {
+func (c *Config) runMergeCommand(ts *chezmoi.TargetState, arg string, entry chezmoi.Entry, tempDir string) error {
 	file, ok := entry.(*chezmoi.File)
 	if !ok {
 		return fmt.Errorf("%s: not a file", arg)
@@ -71,8 +105,8 @@ func (c *Config) runMergeCommand(cmd *cobra.Command, arg string, entry chezmoi.E
 	// source state.
 	args := append(
 		append([]string{}, c.Merge.Args...),
-		filepath.Join(c.DestDir, file.TargetName()),
-		filepath.Join(c.SourceDir, file.SourceName()),
+		ts.TargetPath(file.TargetName()),
+		filepath.Join(ts.EntrySourceDir(file), file.SourceName()),
 	)
 
 	// Try to evaluate the target state. If this succeeds, perform a three-way
@@ -80,7 +114,7 @@ func (c *Config) runMergeCommand(cmd *cobra.Command, arg string, entry chezmoi.E
 	// state. Target state evaluation might fail if the source state contains
 	// template errors or cannot be decrypted.
 	if contents, err := file.Contents(); err != nil {
-		cmd.Printf("warning: %s: cannot evaluate target state: %v\n", arg, err)
+		fmt.Fprintf(c.Stderr, "warning: %s: cannot evaluate target state: %v\n", arg, err)
 	} else {
 		targetStatePath := filepath.Join(tempDir, filepath.Base(file.TargetName()))
 		if err := ioutil.WriteFile(targetStatePath, contents, 0o600); err != nil {
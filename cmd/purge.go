@@ -40,9 +40,10 @@ func (c *Config) runPurgeCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 	paths = append(paths,
+		c.getCacheDir(),
 		c.configFile,
 		c.getPersistentStateFile(),
-		c.SourceDir,
+		c.getSourceDir(),
 	)
 
 	// Remove all paths that exist.
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	vfs "github.com/twpayne/go-vfs"
+
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+)
+
+var reAddCmd = &cobra.Command{
+	Use:      "re-add [targets...]",
+	Short:    "Update the source state of modified files from the destination state",
+	Long:     mustGetLongHelp("re-add"),
+	Example:  getExample("re-add"),
+	PreRunE:  config.ensureNoError,
+	RunE:     config.runReAddCmd,
+	PostRunE: config.autoCommitAndAutoPush,
+}
+
+type reAddCmdConfig struct {
+	patchTemplates bool
+}
+
+func init() {
+	rootCmd.AddCommand(reAddCmd)
+
+	persistentFlags := reAddCmd.PersistentFlags()
+	persistentFlags.BoolVar(&config.reAdd.patchTemplates, "patch-templates", false, "patch templates with changes to the files they generate")
+
+	markRemainingZshCompPositionalArgumentsAsFiles(reAddCmd, 1)
+}
+
+func (c *Config) runReAddCmd(cmd *cobra.Command, args []string) error {
+	ts, err := c.getTargetState(nil)
+	if err != nil {
+		return err
+	}
+
+	var entries []chezmoi.Entry
+	if len(args) == 0 {
+		entries = topLevelEntries(ts)
+	} else {
+		entries, err = c.getEntries(ts, args)
+		if err != nil {
+			return err
+		}
+	}
+
+	var persistentState chezmoi.PersistentState
+	if c.reAdd.patchTemplates {
+		persistentState, err = c.getPersistentState(nil)
+		if err != nil {
+			return err
+		}
+		defer persistentState.Close()
+	}
+
+	readOnlyFS := vfs.NewReadOnlyFS(c.fs)
+	var templates, unpatchedTemplates []string
+	for _, entry := range chezmoi.SortedEntries(entries, ts.TargetIgnore.Match) {
+		file, ok := entry.(*chezmoi.File)
+		// The contents of modify_ files are generated by scripts, and files
+		// from externals do not have their own source files, so there is
+		// nothing to re-add.
+		if !ok || file.Modify || file.SourcePath() == "" {
+			continue
+		}
+		targetPath := ts.TargetPath(file.TargetName())
+		info, err := readOnlyFS.Lstat(targetPath)
+		switch {
+		case chezmoi.IsNotExist(err):
+			continue
+		case err != nil:
+			return err
+		case !info.Mode().IsRegular():
+			continue
+		}
+		contents, err := readOnlyFS.ReadFile(targetPath)
+		if err != nil {
+			return err
+		}
+		targetContents, err := file.Contents()
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(contents, targetContents) {
+			continue
+		}
+		switch {
+		case file.Template && c.reAdd.patchTemplates:
+			patched, err := c.patchTemplateSource(ts, persistentState, file, contents)
+			if err != nil {
+				return err
+			}
+			if !patched {
+				unpatchedTemplates = append(unpatchedTemplates, targetPath)
+			}
+			continue
+		case file.Template:
+			templates = append(templates, targetPath)
+			continue
+		}
+		if err := c.reAddFile(ts, file, contents); err != nil {
+			return err
+		}
+	}
+
+	if len(templates) != 0 {
+		cmd.Printf("warning: skipping files generated by templates, use --patch-templates, chezmoi edit, or chezmoi merge instead: %s\n", strings.Join(templates, ", "))
+	}
+	if len(unpatchedTemplates) != 0 {
+		return fmt.Errorf("cannot patch templates: %s", strings.Join(unpatchedTemplates, ", "))
+	}
+	return nil
+}
+
+// patchTemplateSource applies the changes between the target state of file and
+// contents to file's template, decrypting and encrypting it if file is
+// encrypted. If the changes cannot be applied then it prints why to c.Stderr,
+// leaves the template unchanged, and returns false. Files without their own
+// source files are never patched.
+func (c *Config) patchTemplateSource(ts *chezmoi.TargetState, persistentState chezmoi.PersistentState, file *chezmoi.File, contents []byte) (bool, error) {
+	sourcePath := file.SourcePath()
+	if sourcePath == "" {
+		return false, nil
+	}
+	targetPath := ts.TargetPath(file.TargetName())
+	rendered, err := file.Contents()
+	if err != nil {
+		return false, err
+	}
+
+	// The changes are only the user's if the template still generates what it
+	// generated when it was last applied.
+	applyOptions := &chezmoi.ApplyOptions{
+		PersistentState:  persistentState,
+		EntryStateBucket: c.entryStateBucket,
+	}
+	entryState, err := chezmoi.GetEntryState(applyOptions, targetPath)
+	if err != nil {
+		return false, err
+	}
+	if entryState != nil && !entryState.MatchesContents(rendered) {
+		fmt.Fprintf(c.Stderr, "warning: %s: template output has changed since the last apply\n", targetPath)
+		return false, nil
+	}
+
+	info, err := c.fs.Stat(sourcePath)
+	if err != nil {
+		return false, err
+	}
+	currSourceContents, err := c.fs.ReadFile(sourcePath)
+	if err != nil {
+		return false, err
+	}
+	template := currSourceContents
+	if file.Encrypted {
+		template, err = ts.GPG.Decrypt(sourcePath, currSourceContents)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", sourcePath, err)
+		}
+	}
+
+	patched, conflicts := chezmoi.PatchTemplate(template, rendered, contents)
+	if len(conflicts) == 0 {
+		// Template actions can span lines, so check that the patched template
+		// generates contents.
+		if newRendered, err := ts.ExecuteTemplateData(sourcePath, patched); err != nil || !bytes.Equal(newRendered, contents) {
+			conflicts = []*chezmoi.TemplateConflict{
+				chezmoi.NewTemplateConflict(template, rendered, contents),
+			}
+		}
+	}
+	if len(conflicts) != 0 {
+		fmt.Fprintf(c.Stderr, "warning: %s: changes conflict with template actions in %s\n", targetPath, sourcePath)
+		for _, conflict := range conflicts {
+			fmt.Fprint(c.Stderr, conflict)
+		}
+		return false, nil
+	}
+
+	sourceContents := patched
+	if file.Encrypted {
+		sourceContents, err = ts.GPG.Encrypt(file.TargetName(), patched)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", sourcePath, err)
+		}
+	}
+	if err := c.mutator.WriteFile(sourcePath, sourceContents, info.Mode().Perm(), currSourceContents); err != nil {
+		return false, err
+	}
+
+	// The template now generates contents, so record contents as the last
+	// applied state of the target.
+	if !c.DryRun {
+		if err := chezmoi.SetEntryState(applyOptions, targetPath, contents); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// reAddFile replaces the source state of file with contents, encrypting them if
+// file is encrypted. The source file keeps its name, and so its attributes,
+// and its permissions.
+func (c *Config) reAddFile(ts *chezmoi.TargetState, file *chezmoi.File, contents []byte) error {
+	sourcePath := file.SourcePath()
+	info, err := c.fs.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+	currSourceContents, err := c.fs.ReadFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	sourceContents := contents
+	if file.Encrypted {
+		sourceContents, err = ts.GPG.Encrypt(file.TargetName(), contents)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sourcePath, err)
+		}
+	}
+	return c.mutator.WriteFile(sourcePath, sourceContents, info.Mode().Perm(), currSourceContents)
+}
@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
@@ -43,8 +42,11 @@ func (c *Config) runRemoveCmd(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 	for _, entry := range entries {
-		destDirPath := filepath.Join(c.DestDir, entry.TargetName())
-		sourceDirPath := filepath.Join(c.SourceDir, entry.SourceName())
+		destDirPath := ts.TargetPath(entry.TargetName())
+		sourceDirPath, err := c.getWritableSourcePath(ts, entry)
+		if err != nil {
+			return err
+		}
 		if !c.remove.force {
 			choice, err := c.prompt(fmt.Sprintf("Remove %s and %s", destDirPath, sourceDirPath), "ynqa")
 			if err != nil {
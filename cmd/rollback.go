@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:     "rollback",
+	Args:    cobra.NoArgs,
+	Short:   "Undo the changes made to targets by an apply",
+	Long:    mustGetLongHelp("rollback"),
+	Example: getExample("rollback"),
+	PreRunE: config.ensureNoError,
+	RunE:    config.runRollbackCmd,
+}
+
+type rollbackCmdConfig struct {
+	applyID string
+	list    bool
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+
+	persistentFlags := rollbackCmd.PersistentFlags()
+	persistentFlags.StringVar(&config.rollback.applyID, "apply-id", "", "roll back the apply with id")
+	persistentFlags.BoolVarP(&config.rollback.list, "list", "l", false, "list applies that can be rolled back")
+}
+
+func (c *Config) runRollbackCmd(cmd *cobra.Command, args []string) error {
+	persistentState, err := c.getPersistentState(nil)
+	if err != nil {
+		return err
+	}
+	defer persistentState.Close()
+
+	backupSets, err := chezmoi.LoadBackupSets(persistentState, c.backupBucket)
+	if err != nil {
+		return err
+	}
+
+	if c.rollback.list {
+		for _, backupSet := range backupSets {
+			fmt.Fprintf(c.Stdout, "%s %s %d\n", backupSet.ID, backupSet.CreatedAt.Format("2006-01-02 15:04:05"), len(backupSet.Entries))
+		}
+		return nil
+	}
+
+	var backupSet *chezmoi.BackupSet
+	switch {
+	case len(backupSets) == 0:
+		return errors.New("no applies to roll back")
+	case c.rollback.applyID == "":
+		backupSet = backupSets[len(backupSets)-1]
+	default:
+		for _, s := range backupSets {
+			if s.ID == c.rollback.applyID {
+				backupSet = s
+				break
+			}
+		}
+		if backupSet == nil {
+			return fmt.Errorf("%s: apply not found", c.rollback.applyID)
+		}
+	}
+
+	keptPaths, err := backupSet.Restore(c.fs, c.mutator)
+	if err != nil {
+		return err
+	}
+	for _, path := range keptPaths {
+		fmt.Fprintf(c.Stderr, "warning: %s: not removed, directory is not empty\n", path)
+	}
+	for _, path := range backupSet.Skipped {
+		fmt.Fprintf(c.Stderr, "warning: %s: not restored, file was not backed up\n", path)
+	}
+	if c.DryRun {
+		return nil
+	}
+	// The restored targets were not written by chezmoi, so forget their
+	// recorded states.
+	for _, backupEntry := range backupSet.Entries {
+		if err := persistentState.Delete(c.entryStateBucket, []byte(backupEntry.Path)); err != nil {
+			return err
+		}
+	}
+	return backupSet.Delete(persistentState, c.backupBucket)
+}
@@ -70,7 +70,7 @@ func init() {
 	persistentFlags.BoolVar(&config.Remove, "remove", false, "remove targets")
 	panicOnError(viper.BindPFlag("remove", persistentFlags.Lookup("remove")))
 
-	persistentFlags.StringVarP(&config.SourceDir, "source", "S", getDefaultSourceDir(config.bds), "source directory")
+	persistentFlags.StringArrayVarP(&config.SourceDir, "source", "S", []string{getDefaultSourceDir(config.bds)}, "source directories")
 	panicOnError(viper.BindPFlag("source", persistentFlags.Lookup("source")))
 	panicOnError(rootCmd.MarkPersistentFlagDirname("source"))
 
@@ -175,6 +175,12 @@ func (c *Config) persistentPreRunRootE(cmd *cobra.Command, args []string) error
 		}
 	}
 
+	switch c.Mode {
+	case chezmoi.ModeFile, chezmoi.ModeSymlink:
+	default:
+		return fmt.Errorf("invalid mode: %s", c.Mode)
+	}
+
 	if c.colored {
 		if err := enableVirtualTerminalProcessingOnWindows(c.Stdout); err != nil {
 			return err
@@ -183,6 +189,17 @@ func (c *Config) persistentPreRunRootE(cmd *cobra.Command, args []string) error
 
 	c.fs = vfs.OSFS
 	c.mutator = chezmoi.NewFSMutator(config.fs)
+	privilegedDirs, err := c.getPrivilegedDirs()
+	if err != nil {
+		return err
+	}
+	if len(privilegedDirs) > 0 {
+		dirs := make([]string, 0, len(privilegedDirs))
+		for _, dir := range privilegedDirs {
+			dirs = append(dirs, dir)
+		}
+		c.mutator = chezmoi.NewPrivilegedMutator(c.mutator, c.fs, c.Privileged.Command, c.Privileged.Args, dirs)
+	}
 	if c.DryRun {
 		c.mutator = chezmoi.NullMutator{}
 	}
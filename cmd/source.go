@@ -18,5 +18,5 @@ func init() {
 }
 
 func (c *Config) runSourceCmd(cmd *cobra.Command, args []string) error {
-	return c.run(c.SourceDir, c.SourceVCS.Command, args...)
+	return c.run(c.getSourceDir(), c.SourceVCS.Command, args...)
 }
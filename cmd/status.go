@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	vfs "github.com/twpayne/go-vfs"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+)
+
+var statusCmd = &cobra.Command{
+	Use:     "status [targets...]",
+	Short:   "Show the status of targets",
+	Long:    mustGetLongHelp("status"),
+	Example: getExample("status"),
+	PreRunE: config.ensureNoError,
+	RunE:    config.runStatusCmd,
+}
+
+type statusCmdConfig struct {
+	format string
+}
+
+// Status codes.
+const (
+	statusAdded    = "A"
+	statusDeleted  = "D"
+	statusModified = "M"
+	statusRun      = "R"
+)
+
+// A targetStatus is the status of a target. SinceApply is how the destination
+// state has changed since the target was last applied, and ToApply is how
+// applying the target state would change the destination state.
+type targetStatus struct {
+	Path       string `json:"path" toml:"path" yaml:"path"`
+	SinceApply string `json:"sinceApply" toml:"sinceApply" yaml:"sinceApply"`
+	ToApply    string `json:"toApply" toml:"toApply" yaml:"toApply"`
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	persistentFlags := statusCmd.PersistentFlags()
+	persistentFlags.StringVarP(&config.status.format, "format", "f", "", "format (JSON or YAML)")
+
+	markRemainingZshCompPositionalArgumentsAsFiles(statusCmd, 1)
+}
+
+func (c *Config) runStatusCmd(cmd *cobra.Command, args []string) error {
+	var format func(w io.Writer, value interface{}) error
+	if c.status.format != "" {
+		var ok bool
+		format, ok = formatMap[strings.ToLower(c.status.format)]
+		if !ok {
+			return fmt.Errorf("%s: unknown format", c.status.format)
+		}
+	}
+
+	c.DryRun = true // Prevent scripts from running.
+	recordingMutator := chezmoi.NewRecordingMutator(chezmoi.NullMutator{}, vfs.NewReadOnlyFS(c.fs))
+	c.mutator = recordingMutator
+
+	persistentState, err := c.getPersistentState(&bolt.Options{
+		ReadOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer persistentState.Close()
+
+	ts, applyOptions, entries, err := c.getApplyEntries(args, persistentState)
+	if err != nil {
+		return err
+	}
+	applyOptions.Verbose = false
+	if err := c.applyEntries(ts, applyOptions, entries); err != nil {
+		return err
+	}
+	if entries == nil {
+		entries = topLevelEntries(ts)
+	}
+
+	readOnlyFS := vfs.NewReadOnlyFS(c.fs)
+	statuses := make(map[string]*targetStatus)
+	getStatus := func(targetPath string) *targetStatus {
+		if status, ok := statuses[targetPath]; ok {
+			return status
+		}
+		path := targetPath
+		if relPath, err := filepath.Rel(ts.DestDir, targetPath); err == nil && !strings.HasPrefix(relPath, "..") {
+			path = relPath
+		}
+		status := &targetStatus{
+			Path: path,
+		}
+		statuses[targetPath] = status
+		return status
+	}
+
+	// Compare the destination state with the state of each file when it was
+	// last applied, and find the scripts that would run.
+	for _, entry := range chezmoi.SortedEntries(entries, applyOptions.Ignore) {
+		targetPath := applyOptions.TargetPath(entry.TargetName())
+		switch entry := entry.(type) {
+		case *chezmoi.File:
+			entryState, err := chezmoi.GetEntryState(applyOptions, targetPath)
+			if err != nil {
+				return err
+			}
+			if entryState == nil {
+				continue
+			}
+			info, err := readOnlyFS.Lstat(targetPath)
+			switch {
+			case chezmoi.IsNotExist(err):
+				getStatus(targetPath).SinceApply = statusDeleted
+			case err != nil:
+				return err
+			case !info.Mode().IsRegular():
+				getStatus(targetPath).SinceApply = statusModified
+			default:
+				contents, err := readOnlyFS.ReadFile(targetPath)
+				if err != nil {
+					return err
+				}
+				if !entryState.MatchesContents(contents) {
+					getStatus(targetPath).SinceApply = statusModified
+				}
+			}
+		case *chezmoi.Script:
+			// Scripts print their contents in verbose dry runs only if they
+			// would run.
+			output := &bytes.Buffer{}
+			scriptApplyOptions := *applyOptions
+			scriptApplyOptions.Stdout = output
+			scriptApplyOptions.Verbose = true
+			if err := entry.Apply(readOnlyFS, chezmoi.NullMutator{}, c.Follow, &scriptApplyOptions); err != nil {
+				return err
+			}
+			if output.Len() != 0 {
+				getStatus(targetPath).ToApply = statusRun
+			}
+		}
+	}
+
+	// Compare the target state with the destination state.
+	for _, op := range recordingMutator.Ops() {
+		if op.OldPath != "" {
+			getStatus(op.OldPath).ToApply = statusDeleted
+		}
+		status := getStatus(op.Path)
+		switch _, err := readOnlyFS.Lstat(op.Path); {
+		case op.Op == chezmoi.OpRemoveAll:
+			status.ToApply = statusDeleted
+		case chezmoi.IsNotExist(err):
+			status.ToApply = statusAdded
+		case err != nil:
+			return err
+		default:
+			status.ToApply = statusModified
+		}
+	}
+
+	targetPaths := make([]string, 0, len(statuses))
+	for targetPath := range statuses {
+		targetPaths = append(targetPaths, targetPath)
+	}
+	sort.Strings(targetPaths)
+	sortedStatuses := make([]*targetStatus, 0, len(targetPaths))
+	for _, targetPath := range targetPaths {
+		sortedStatuses = append(sortedStatuses, statuses[targetPath])
+	}
+
+	if format != nil {
+		return format(c.Stdout, sortedStatuses)
+	}
+	for _, status := range sortedStatuses {
+		if _, err := fmt.Fprintf(c.Stdout, "%1s%1s %s\n", status.SinceApply, status.ToApply, status.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -15,7 +15,18 @@ func init() {
 }
 
 func (c *Config) includeFunc(filename string) string {
-	contents, err := c.fs.ReadFile(filepath.Join(c.SourceDir, filename))
+	// Search the source directories in reverse order so that later source
+	// directories override earlier ones.
+	var contents []byte
+	err := os.ErrNotExist
+	for i := len(c.SourceDir) - 1; i >= 0 && os.IsNotExist(err); i-- {
+		var sourceRootDir string
+		sourceRootDir, err = c.getSourceRootDir(c.SourceDir[i])
+		if err != nil {
+			panic(err)
+		}
+		contents, err = c.fs.ReadFile(filepath.Join(sourceRootDir, filename))
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -50,7 +50,7 @@ func (c *Config) runUpdateCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("%s: pull not supported", c.SourceVCS.Command)
 	}
 
-	if err := c.run(c.SourceDir, c.SourceVCS.Command, pullArgs...); err != nil {
+	if err := c.run(c.getSourceDir(), c.SourceVCS.Command, pullArgs...); err != nil {
 		return err
 	}
 
@@ -60,7 +60,7 @@ func (c *Config) runUpdateCmd(cmd *cobra.Command, args []string) error {
 			return err
 		}
 		defer persistentState.Close()
-		if err := c.applyArgs(nil, persistentState); err != nil {
+		if err := c.applyArgsWithState(nil, persistentState); err != nil {
 			return err
 		}
 	}
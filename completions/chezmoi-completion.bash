@@ -369,6 +369,7 @@ _chezmoi_add()
 
     flags+=("--autotemplate")
     flags+=("-a")
+    flags+=("--create")
     flags+=("--empty")
     flags+=("-e")
     flags+=("--encrypt")
@@ -432,6 +433,16 @@ _chezmoi_apply()
     flags_with_completion=()
     flags_completion=()
 
+    flags+=("--export-script=")
+    two_word_flags+=("--export-script")
+    flags_with_completion+=("--export-script")
+    flags_completion+=("_filedir")
+    flags+=("--force")
+    flags+=("--format=")
+    two_word_flags+=("--format")
+    flags+=("--interactive")
+    flags+=("-i")
+    flags+=("--refresh-externals")
     flags+=("--color=")
     two_word_flags+=("--color")
     flags+=("--config=")
@@ -639,6 +650,7 @@ _chezmoi_chattr()
     flags_with_completion=()
     flags_completion=()
 
+    flags+=("--attributes-file")
     flags+=("--color=")
     two_word_flags+=("--color")
     flags+=("--config=")
@@ -1544,6 +1556,7 @@ _chezmoi_merge()
     flags_with_completion=()
     flags_completion=()
 
+    flags+=("--patch-templates")
     flags+=("--color=")
     two_word_flags+=("--color")
     flags+=("--config=")
@@ -1632,6 +1645,57 @@ _chezmoi_purge()
     noun_aliases=()
 }
 
+_chezmoi_re-add()
+{
+    last_command="chezmoi_re-add"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--patch-templates")
+    flags+=("--color=")
+    two_word_flags+=("--color")
+    flags+=("--config=")
+    two_word_flags+=("--config")
+    flags_with_completion+=("--config")
+    flags_completion+=("_filedir")
+    two_word_flags+=("-c")
+    flags_with_completion+=("-c")
+    flags_completion+=("_filedir")
+    flags+=("--debug")
+    flags+=("--destination=")
+    two_word_flags+=("--destination")
+    flags_with_completion+=("--destination")
+    flags_completion+=("_filedir -d")
+    two_word_flags+=("-D")
+    flags_with_completion+=("-D")
+    flags_completion+=("_filedir -d")
+    flags+=("--dry-run")
+    flags+=("-n")
+    flags+=("--follow")
+    flags+=("--remove")
+    flags+=("--source=")
+    two_word_flags+=("--source")
+    flags_with_completion+=("--source")
+    flags_completion+=("_filedir -d")
+    two_word_flags+=("-S")
+    flags_with_completion+=("-S")
+    flags_completion+=("_filedir -d")
+    flags+=("--verbose")
+    flags+=("-v")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
 _chezmoi_remove()
 {
     last_command="chezmoi_remove"
@@ -1684,6 +1748,60 @@ _chezmoi_remove()
     noun_aliases=()
 }
 
+_chezmoi_rollback()
+{
+    last_command="chezmoi_rollback"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--apply-id=")
+    two_word_flags+=("--apply-id")
+    flags+=("--list")
+    flags+=("-l")
+    flags+=("--color=")
+    two_word_flags+=("--color")
+    flags+=("--config=")
+    two_word_flags+=("--config")
+    flags_with_completion+=("--config")
+    flags_completion+=("_filedir")
+    two_word_flags+=("-c")
+    flags_with_completion+=("-c")
+    flags_completion+=("_filedir")
+    flags+=("--debug")
+    flags+=("--destination=")
+    two_word_flags+=("--destination")
+    flags_with_completion+=("--destination")
+    flags_completion+=("_filedir -d")
+    two_word_flags+=("-D")
+    flags_with_completion+=("-D")
+    flags_completion+=("_filedir -d")
+    flags+=("--dry-run")
+    flags+=("-n")
+    flags+=("--follow")
+    flags+=("--remove")
+    flags+=("--source=")
+    two_word_flags+=("--source")
+    flags_with_completion+=("--source")
+    flags_completion+=("_filedir -d")
+    two_word_flags+=("-S")
+    flags_with_completion+=("-S")
+    flags_completion+=("_filedir -d")
+    flags+=("--verbose")
+    flags+=("-v")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
 _chezmoi_secret_bitwarden()
 {
     last_command="chezmoi_secret_bitwarden"
@@ -2411,6 +2529,59 @@ _chezmoi_source-path()
     noun_aliases=()
 }
 
+_chezmoi_status()
+{
+    last_command="chezmoi_status"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--format=")
+    two_word_flags+=("--format")
+    two_word_flags+=("-f")
+    flags+=("--color=")
+    two_word_flags+=("--color")
+    flags+=("--config=")
+    two_word_flags+=("--config")
+    flags_with_completion+=("--config")
+    flags_completion+=("_filedir")
+    two_word_flags+=("-c")
+    flags_with_completion+=("-c")
+    flags_completion+=("_filedir")
+    flags+=("--debug")
+    flags+=("--destination=")
+    two_word_flags+=("--destination")
+    flags_with_completion+=("--destination")
+    flags_completion+=("_filedir -d")
+    two_word_flags+=("-D")
+    flags_with_completion+=("-D")
+    flags_completion+=("_filedir -d")
+    flags+=("--dry-run")
+    flags+=("-n")
+    flags+=("--follow")
+    flags+=("--remove")
+    flags+=("--source=")
+    two_word_flags+=("--source")
+    flags_with_completion+=("--source")
+    flags_completion+=("_filedir -d")
+    two_word_flags+=("-S")
+    flags_with_completion+=("-S")
+    flags_completion+=("_filedir -d")
+    flags+=("--verbose")
+    flags+=("-v")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
 _chezmoi_unmanaged()
 {
     last_command="chezmoi_unmanaged"
@@ -2663,14 +2834,17 @@ _chezmoi_root_command()
     commands+=("managed")
     commands+=("merge")
     commands+=("purge")
+    commands+=("re-add")
     commands+=("remove")
     if [[ -z "${BASH_VERSION}" || "${BASH_VERSINFO[0]}" -gt 3 ]]; then
         command_aliases+=("rm")
         aliashash["rm"]="remove"
     fi
+    commands+=("rollback")
     commands+=("secret")
     commands+=("source")
     commands+=("source-path")
+    commands+=("status")
     commands+=("unmanaged")
     commands+=("update")
     commands+=("upgrade")
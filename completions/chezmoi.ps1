@@ -27,8 +27,8 @@ Register-ArgumentCompleter -Native -CommandName 'chezmoi' -ScriptBlock {
             [CompletionResult]::new('--dry-run', 'dry-run', [CompletionResultType]::ParameterName, 'dry run')
             [CompletionResult]::new('--follow', 'follow', [CompletionResultType]::ParameterName, 'follow symlinks')
             [CompletionResult]::new('--remove', 'remove', [CompletionResultType]::ParameterName, 'remove targets')
-            [CompletionResult]::new('-S', 'S', [CompletionResultType]::ParameterName, 'source directory')
-            [CompletionResult]::new('--source', 'source', [CompletionResultType]::ParameterName, 'source directory')
+            [CompletionResult]::new('-S', 'S', [CompletionResultType]::ParameterName, 'source directories')
+            [CompletionResult]::new('--source', 'source', [CompletionResultType]::ParameterName, 'source directories')
             [CompletionResult]::new('-v', 'v', [CompletionResultType]::ParameterName, 'verbose')
             [CompletionResult]::new('--verbose', 'verbose', [CompletionResultType]::ParameterName, 'verbose')
             [CompletionResult]::new('add', 'add', [CompletionResultType]::ParameterValue, 'Add an existing file, directory, or symlink to the source state')
@@ -55,10 +55,13 @@ Register-ArgumentCompleter -Native -CommandName 'chezmoi' -ScriptBlock {
             [CompletionResult]::new('managed', 'managed', [CompletionResultType]::ParameterValue, 'List the managed files in the destination directory')
             [CompletionResult]::new('merge', 'merge', [CompletionResultType]::ParameterValue, 'Perform a three-way merge between the destination state, the source state, and the target state')
             [CompletionResult]::new('purge', 'purge', [CompletionResultType]::ParameterValue, 'Purge all of chezmoi''s configuration and data')
+            [CompletionResult]::new('re-add', 're-add', [CompletionResultType]::ParameterValue, 'Update the source state of modified files from the destination state')
             [CompletionResult]::new('remove', 'remove', [CompletionResultType]::ParameterValue, 'Remove a target from the source state and the destination directory')
+            [CompletionResult]::new('rollback', 'rollback', [CompletionResultType]::ParameterValue, 'Undo the changes made to targets by an apply')
             [CompletionResult]::new('secret', 'secret', [CompletionResultType]::ParameterValue, 'Interact with a secret manager')
             [CompletionResult]::new('source', 'source', [CompletionResultType]::ParameterValue, 'Run the source version control system command in the source directory')
             [CompletionResult]::new('source-path', 'source-path', [CompletionResultType]::ParameterValue, 'Print the path of a target in the source state')
+            [CompletionResult]::new('status', 'status', [CompletionResultType]::ParameterValue, 'Show the status of targets')
             [CompletionResult]::new('unmanaged', 'unmanaged', [CompletionResultType]::ParameterValue, 'List the unmanaged files in the destination directory')
             [CompletionResult]::new('update', 'update', [CompletionResultType]::ParameterValue, 'Pull changes from the source VCS and apply any changes')
             [CompletionResult]::new('upgrade', 'upgrade', [CompletionResultType]::ParameterValue, 'Upgrade chezmoi to the latest released version')
@@ -98,8 +101,8 @@ Register-ArgumentCompleter -Native -CommandName 'chezmoi' -ScriptBlock {
             [CompletionResult]::new('-o', 'o', [CompletionResultType]::ParameterName, 'output filename')
             [CompletionResult]::new('--output', 'output', [CompletionResultType]::ParameterName, 'output filename')
             [CompletionResult]::new('--remove', 'remove', [CompletionResultType]::ParameterName, 'remove targets')
-            [CompletionResult]::new('-S', 'S', [CompletionResultType]::ParameterName, 'source directory')
-            [CompletionResult]::new('--source', 'source', [CompletionResultType]::ParameterName, 'source directory')
+            [CompletionResult]::new('-S', 'S', [CompletionResultType]::ParameterName, 'source directories')
+            [CompletionResult]::new('--source', 'source', [CompletionResultType]::ParameterName, 'source directories')
             [CompletionResult]::new('-v', 'v', [CompletionResultType]::ParameterName, 'verbose')
             [CompletionResult]::new('--verbose', 'verbose', [CompletionResultType]::ParameterName, 'verbose')
             break
@@ -155,9 +158,15 @@ Register-ArgumentCompleter -Native -CommandName 'chezmoi' -ScriptBlock {
         'chezmoi;purge' {
             break
         }
+        'chezmoi;re-add' {
+            break
+        }
         'chezmoi;remove' {
             break
         }
+        'chezmoi;rollback' {
+            break
+        }
         'chezmoi;secret' {
             [CompletionResult]::new('bitwarden', 'bitwarden', [CompletionResultType]::ParameterValue, 'Execute the Bitwarden CLI (bw)')
             [CompletionResult]::new('generic', 'generic', [CompletionResultType]::ParameterValue, 'Execute a generic secret command')
@@ -211,6 +220,9 @@ Register-ArgumentCompleter -Native -CommandName 'chezmoi' -ScriptBlock {
         'chezmoi;source-path' {
             break
         }
+        'chezmoi;status' {
+            break
+        }
         'chezmoi;unmanaged' {
             break
         }
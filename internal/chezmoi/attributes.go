@@ -0,0 +1,342 @@
+package chezmoi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/bmatcuk/doublestar/v2"
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// AttributesName is the name of the file that sets attributes in the source
+// state.
+const AttributesName = ".chezmoiattributes"
+
+// Attributes that can be set in .chezmoiattributes files.
+const (
+	AttributeCreate     = "create"
+	AttributeEmpty      = "empty"
+	AttributeEncrypted  = "encrypted"
+	AttributeExact      = "exact"
+	AttributeExecutable = "executable"
+	AttributePrivate    = "private"
+	AttributeReadOnly   = "readonly"
+	AttributeTemplate   = "template"
+)
+
+// Attributes with values that can be set in .chezmoiattributes files.
+const (
+	AttributeGroup = "group"
+	AttributeOwner = "owner"
+)
+
+var knownValueAttributes = map[string]bool{
+	AttributeGroup: true,
+	AttributeOwner: true,
+}
+
+var knownAttributes = map[string]bool{
+	AttributeCreate:     true,
+	AttributeEmpty:      true,
+	AttributeEncrypted:  true,
+	AttributeExact:      true,
+	AttributeExecutable: true,
+	AttributePrivate:    true,
+	AttributeReadOnly:   true,
+	AttributeTemplate:   true,
+}
+
+// An attributeRule sets or unsets attributes of the targets that match
+// pattern.
+type attributeRule struct {
+	pattern    string
+	attributes map[string]bool
+	values     map[string]string
+}
+
+// FormatAttributeLine returns a line for a .chezmoiattributes file in the root
+// of the source state that sets or unsets attributes on the target targetName
+// only.
+func FormatAttributeLine(targetName string, attributes map[string]bool) string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := []string{"/" + escapePattern(filepath.ToSlash(targetName))}
+	for _, name := range names {
+		if attributes[name] {
+			fields = append(fields, name)
+		} else {
+			fields = append(fields, "-"+name)
+		}
+	}
+	return strings.Join(fields, " ") + "\n"
+}
+
+// addAttributes adds the attribute rules in the .chezmoiattributes file at
+// path to ts. relPath is the target path of the file.
+func (ts *TargetState) addAttributes(fs vfs.FS, path, relPath string) error {
+	data, err := ts.executeTemplate(fs, path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.ToSlash(filepath.Dir(relPath))
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		fields := splitAttributeLine(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// As in .gitattributes, patterns without a slash match at any depth
+		// and a leading slash anchors a pattern to the directory.
+		pattern := fields[0]
+		switch {
+		case strings.HasPrefix(pattern, "/"):
+			pattern = strings.TrimPrefix(pattern, "/")
+		case !strings.Contains(pattern, "/"):
+			pattern = "**/" + pattern
+		}
+		if dir != "." {
+			pattern = dir + "/" + pattern
+		}
+		if _, err := doublestar.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		rule := &attributeRule{
+			pattern:    pattern,
+			attributes: make(map[string]bool),
+			values:     make(map[string]string),
+		}
+		for _, field := range fields[1:] {
+			if index := strings.IndexRune(field, '='); index != -1 {
+				name := field[:index]
+				if !knownValueAttributes[name] {
+					return fmt.Errorf("%s: %s: unknown attribute", path, name)
+				}
+				rule.values[name] = field[index+1:]
+				continue
+			}
+			value := true
+			if strings.HasPrefix(field, "-") {
+				value = false
+				field = strings.TrimPrefix(field, "-")
+			}
+			if !knownAttributes[field] {
+				return fmt.Errorf("%s: %s: unknown attribute", path, field)
+			}
+			rule.attributes[field] = value
+		}
+		ts.attributeRules = append(ts.attributeRules, rule)
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// addAttributesFiles adds the attribute rules in all .chezmoiattributes files
+// in sourceDirs to ts.
+func (ts *TargetState) addAttributesFiles(fs vfs.FS, sourceDirs []string) error {
+	for _, sourceDir := range sourceDirs {
+		sourceDir := sourceDir
+		if err := vfs.Walk(fs, sourceDir, func(path string, info os.FileInfo, _ error) error {
+			if path == sourceDir {
+				return nil
+			}
+			switch name := info.Name(); {
+			case name == AttributesName && info.Mode().IsRegular():
+				relPath, err := filepath.Rel(sourceDir, path)
+				if err != nil {
+					return err
+				}
+				dns, err := ts.dirNames(parseDirNameComponents(splitPathList(relPath)))
+				if err != nil {
+					return err
+				}
+				return ts.addAttributes(fs, path, filepath.Join(dns...))
+			case strings.HasPrefix(name, ".") && info.IsDir():
+				return filepath.SkipDir
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchAttributes returns the attributes set or unset on targetName. Later
+// rules take precedence over earlier ones.
+func (ts *TargetState) matchAttributes(targetName string) map[string]bool {
+	var attributes map[string]bool
+	for _, rule := range ts.attributeRules {
+		if ok, _ := doublestar.Match(rule.pattern, filepath.ToSlash(targetName)); !ok {
+			continue
+		}
+		if attributes == nil {
+			attributes = make(map[string]bool)
+		}
+		for name, value := range rule.attributes {
+			attributes[name] = value
+		}
+	}
+	return attributes
+}
+
+// matchOwnership returns the owner and group set on targetName. Later rules
+// take precedence over earlier ones, and an empty value unsets the owner or
+// group.
+func (ts *TargetState) matchOwnership(targetName string) (string, string) {
+	var owner, group string
+	for _, rule := range ts.attributeRules {
+		if len(rule.values) == 0 {
+			continue
+		}
+		if ok, _ := doublestar.Match(rule.pattern, filepath.ToSlash(targetName)); !ok {
+			continue
+		}
+		if value, ok := rule.values[AttributeOwner]; ok {
+			owner = value
+		}
+		if value, ok := rule.values[AttributeGroup]; ok {
+			group = value
+		}
+	}
+	return owner, group
+}
+
+// applyDirAttributes applies the attributes matching targetName to da.
+func (ts *TargetState) applyDirAttributes(targetName string, da *DirAttributes) {
+	attributes := ts.matchAttributes(targetName)
+	if attributes == nil {
+		return
+	}
+	if exact, ok := attributes[AttributeExact]; ok {
+		da.Exact = exact
+	}
+	private := da.Perm&0o77 == 0
+	if value, ok := attributes[AttributePrivate]; ok {
+		private = value
+	}
+	readOnly := da.Perm&0o222 == 0
+	if value, ok := attributes[AttributeReadOnly]; ok {
+		readOnly = value
+	}
+	da.Perm = 0o777
+	if private {
+		da.Perm &= 0o700
+	}
+	if readOnly {
+		da.Perm &^= 0o222
+	}
+}
+
+// applyFileAttributes applies the attributes matching targetName to fa.
+func (ts *TargetState) applyFileAttributes(targetName string, fa *FileAttributes) {
+	attributes := ts.matchAttributes(targetName)
+	if attributes == nil {
+		return
+	}
+	if template, ok := attributes[AttributeTemplate]; ok {
+		fa.Template = template
+	}
+	if fa.Mode&os.ModeType != 0 {
+		return
+	}
+	if create, ok := attributes[AttributeCreate]; ok {
+		fa.Create = create
+	}
+	if empty, ok := attributes[AttributeEmpty]; ok {
+		fa.Empty = empty
+	}
+	if encrypted, ok := attributes[AttributeEncrypted]; ok {
+		fa.Encrypted = encrypted
+	}
+	executable := fa.Mode&0o111 != 0
+	if value, ok := attributes[AttributeExecutable]; ok {
+		executable = value
+	}
+	private := fa.Mode&0o77 == 0
+	if value, ok := attributes[AttributePrivate]; ok {
+		private = value
+	}
+	readOnly := fa.Mode&0o222 == 0
+	if value, ok := attributes[AttributeReadOnly]; ok {
+		readOnly = value
+	}
+	fa.Mode = 0o666
+	if executable {
+		fa.Mode |= 0o111
+	}
+	if private {
+		fa.Mode &= 0o700
+	}
+	if readOnly {
+		fa.Mode &^= 0o222
+	}
+}
+
+// applyScriptAttributes applies the attributes matching targetName to sa.
+func (ts *TargetState) applyScriptAttributes(targetName string, sa *ScriptAttributes) {
+	attributes := ts.matchAttributes(targetName)
+	if encrypted, ok := attributes[AttributeEncrypted]; ok {
+		sa.Encrypted = encrypted
+	}
+	if template, ok := attributes[AttributeTemplate]; ok {
+		sa.Template = template
+	}
+}
+
+// escapePattern escapes the pattern metacharacters, comment characters, and
+// whitespace in name.
+func escapePattern(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[]{}\#`, r) || unicode.IsSpace(r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// splitAttributeLine splits a line of a .chezmoiattributes file into fields
+// separated by whitespace, ignoring everything after a #. Whitespace and #
+// characters escaped with a backslash are part of a field, and escapes are
+// kept so that patterns match them literally.
+func splitAttributeLine(line string) []string {
+	var fields []string
+	var sb strings.Builder
+	inField, escaped := false, false
+FOR:
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '#':
+			break FOR
+		case unicode.IsSpace(r):
+			if inField {
+				fields = append(fields, sb.String())
+				sb.Reset()
+				inField = false
+			}
+			continue
+		}
+		sb.WriteRune(r)
+		inField = true
+	}
+	if inField {
+		fields = append(fields, sb.String())
+	}
+	return fields
+}
@@ -0,0 +1,202 @@
+package chezmoi
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestAttributes(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/.chezmoiattributes": "" +
+			"# comment\n" +
+			".ssh private exact\n" +
+			".ssh/* private\n" +
+			".ssh/*.sh executable\n" +
+			".bashrc template\n" +
+			".profile -template\n" +
+			"run.sh template\n" +
+			"link template\n",
+		"/dot_ssh/.chezmoiattributes": "" +
+			"config -private\n" +
+			"known_hosts readonly\n",
+		"/dot_ssh/config":      "# contents of .ssh/config\n",
+		"/dot_ssh/id_rsa":      "# contents of .ssh/id_rsa\n",
+		"/dot_ssh/install.sh":  "#!/bin/sh\n",
+		"/dot_ssh/known_hosts": "# contents of .ssh/known_hosts\n",
+		"/dot_bashrc":          "{{ .user }}\n",
+		"/dot_profile.tmpl":    "{{ .user }}\n",
+		"/run_run.sh":          "#!/bin/sh\necho {{ .user }}\n",
+		"/symlink_link":        "{{ .user }}",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/"),
+		WithTemplateData(map[string]interface{}{
+			"user": "user",
+		}),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+
+	ssh, ok := ts.Entries[".ssh"].(*Dir)
+	require.True(t, ok)
+	assert.True(t, ssh.Exact)
+	assert.Equal(t, os.FileMode(0o700), ssh.Perm)
+	for name, perm := range map[string]os.FileMode{
+		"config":      0o666,
+		"id_rsa":      0o600,
+		"install.sh":  0o700,
+		"known_hosts": 0o400,
+	} {
+		file, ok := ssh.Entries[name].(*File)
+		require.True(t, ok)
+		assert.Equal(t, perm, file.Perm, name)
+	}
+
+	for name, want := range map[string]string{
+		".bashrc":  "user\n",
+		".profile": "{{ .user }}\n",
+	} {
+		contents, err := ts.Entries[name].(*File).Contents()
+		require.NoError(t, err)
+		assert.Equal(t, want, string(contents), name)
+	}
+
+	script, ok := ts.Entries["run.sh"].(*Script)
+	require.True(t, ok)
+	assert.True(t, script.Template)
+
+	linkname, err := ts.Entries["link"].(*Symlink).Linkname()
+	require.NoError(t, err)
+	assert.Equal(t, "user", linkname)
+}
+
+func TestAttributesOwnership(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/.chezmoiattributes": "" +
+			"dir owner=12345 group=12345\n" +
+			"dir/* owner=12345\n" +
+			"dir/public owner= group=\n",
+		"/dir/private": "# contents of dir/private\n",
+		"/dir/public":  "# contents of dir/public\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+
+	dir, ok := ts.Entries["dir"].(*Dir)
+	require.True(t, ok)
+	assert.Equal(t, "12345", dir.Owner)
+	assert.Equal(t, "12345", dir.Group)
+	private, ok := dir.Entries["private"].(*File)
+	require.True(t, ok)
+	assert.Equal(t, "12345", private.Owner)
+	assert.Equal(t, "", private.Group)
+	public, ok := dir.Entries["public"].(*File)
+	require.True(t, ok)
+	assert.Equal(t, "", public.Owner)
+	assert.Equal(t, "", public.Group)
+
+	applyOptions := &ApplyOptions{
+		DestDir: ts.DestDir,
+		Ignore:  ts.TargetIgnore.Match,
+		Stdout:  os.Stdout,
+		Umask:   0o22,
+	}
+	b := &bytes.Buffer{}
+	require.NoError(t, ts.Apply(fs, NewVerboseMutator(b, NullMutator{}, false, 0), false, applyOptions))
+	assert.Contains(t, b.String(), "chown 12345:12345 /home/user/dir\n")
+	assert.Contains(t, b.String(), "chown 12345 /home/user/dir/private\n")
+	assert.NotContains(t, b.String(), "chown 12345 /home/user/dir/public\n")
+}
+
+func TestAttributesPatterns(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/.chezmoiattributes": "" +
+			"*.sh executable\n" +
+			"/top private # comment\n" +
+			"My\\ Dir/a\\ file private\n" +
+			"\\#hash private\n",
+		"/dir/.chezmoiattributes": "" +
+			"*.py executable\n",
+		"/#hash":         "# contents of #hash\n",
+		"/My Dir/a file": "# contents of My Dir/a file\n",
+		"/dir/a.py":      "# contents of dir/a.py\n",
+		"/dir/a.sh":      "# contents of dir/a.sh\n",
+		"/dir/sub/b.py":  "# contents of dir/sub/b.py\n",
+		"/dir/top":       "# contents of dir/top\n",
+		"/b.py":          "# contents of b.py\n",
+		"/b.sh":          "# contents of b.sh\n",
+		"/top":           "# contents of top\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+
+	for targetName, perm := range map[string]os.FileMode{
+		"#hash":                             0o600,
+		filepath.Join("My Dir", "a file"):   0o600,
+		filepath.Join("dir", "a.py"):        0o777,
+		filepath.Join("dir", "a.sh"):        0o777,
+		filepath.Join("dir", "sub", "b.py"): 0o777,
+		filepath.Join("dir", "top"):         0o666,
+		"b.py":                              0o666,
+		"b.sh":                              0o777,
+		"top":                               0o600,
+	} {
+		entry, err := ts.findEntry(targetName)
+		require.NoError(t, err)
+		file, ok := entry.(*File)
+		require.True(t, ok, targetName)
+		assert.Equal(t, perm, file.Perm, targetName)
+	}
+}
+
+func TestFormatAttributeLine(t *testing.T) {
+	for _, tc := range []struct {
+		targetName     string
+		attributes     map[string]bool
+		expected       string
+		expectedFields []string
+	}{
+		{
+			targetName: filepath.Join("dir", "file*.txt"),
+			attributes: map[string]bool{
+				AttributePrivate:  false,
+				AttributeTemplate: true,
+			},
+			expected:       "/dir/file\\*.txt -private template\n",
+			expectedFields: []string{"/dir/file\\*.txt", "-private", "template"},
+		},
+		{
+			targetName: filepath.Join("My Dir", "a #file"),
+			attributes: map[string]bool{
+				AttributePrivate: true,
+			},
+			expected:       "/My\\ Dir/a\\ \\#file private\n",
+			expectedFields: []string{"/My\\ Dir/a\\ \\#file", "private"},
+		},
+	} {
+		actual := FormatAttributeLine(tc.targetName, tc.attributes)
+		assert.Equal(t, tc.expected, actual)
+		assert.Equal(t, tc.expectedFields, splitAttributeLine(actual))
+	}
+}
@@ -0,0 +1,264 @@
+package chezmoi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// Backup entry types.
+const (
+	BackupTypeDir     = "dir"
+	BackupTypeFile    = "file"
+	BackupTypeMissing = "missing"
+	BackupTypeSymlink = "symlink"
+)
+
+// backupIDFormat is the format of backup set ids. Ids sort in the order that
+// their backup sets were created.
+const backupIDFormat = "20060102T150405.000000000Z"
+
+// A BackupEntry records the state of a target before it was changed.
+type BackupEntry struct {
+	Path     string      `json:"path"`
+	Type     string      `json:"type"`
+	Mode     os.FileMode `json:"mode,omitempty"`
+	Contents []byte      `json:"contents,omitempty"`
+	Linkname string      `json:"linkname,omitempty"`
+}
+
+// A BackupSet records the state of all targets changed by a single apply.
+// Skipped contains the paths of files that were changed but were too large to
+// record.
+type BackupSet struct {
+	ID        string         `json:"id"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Entries   []*BackupEntry `json:"entries"`
+	Skipped   []string       `json:"skipped,omitempty"`
+}
+
+// NewBackupSet returns a new, empty BackupSet created at createdAt.
+func NewBackupSet(createdAt time.Time) *BackupSet {
+	return &BackupSet{
+		ID:        createdAt.UTC().Format(backupIDFormat),
+		CreatedAt: createdAt,
+	}
+}
+
+// LoadBackupSets returns all backup sets in bucket in persistentState, oldest
+// first.
+func LoadBackupSets(persistentState PersistentState, bucket []byte) ([]*BackupSet, error) {
+	var backupSets []*BackupSet
+	if err := persistentState.ForEach(bucket, func(k, v []byte) error {
+		var backupSet BackupSet
+		if err := json.Unmarshal(v, &backupSet); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+		backupSets = append(backupSets, &backupSet)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(backupSets, func(i, j int) bool {
+		return backupSets[i].ID < backupSets[j].ID
+	})
+	return backupSets, nil
+}
+
+// PruneBackupSets deletes all but the newest keep backup sets in bucket in
+// persistentState.
+func PruneBackupSets(persistentState PersistentState, bucket []byte, keep int) error {
+	var ids []string
+	if err := persistentState.ForEach(bucket, func(k, _ []byte) error {
+		ids = append(ids, string(k))
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(ids) <= keep {
+		return nil
+	}
+	sort.Strings(ids)
+	for _, id := range ids[:len(ids)-keep] {
+		if err := persistentState.Delete(bucket, []byte(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete deletes s from bucket in persistentState.
+func (s *BackupSet) Delete(persistentState PersistentState, bucket []byte) error {
+	return persistentState.Delete(bucket, []byte(s.ID))
+}
+
+// Restore restores all of the targets in s in fs, undoing the most recent
+// changes first. Directories created by the apply are only removed if they are
+// empty, and Restore returns the paths of those that were not removed.
+func (s *BackupSet) Restore(fs vfs.FS, mutator Mutator) ([]string, error) {
+	var keptPaths []string
+	for i := len(s.Entries) - 1; i >= 0; i-- {
+		restored, err := s.Entries[i].restore(fs, mutator)
+		if err != nil {
+			return nil, err
+		}
+		if !restored {
+			keptPaths = append(keptPaths, s.Entries[i].Path)
+		}
+	}
+	return keptPaths, nil
+}
+
+// Save saves s in bucket in persistentState. Empty backup sets are not saved.
+func (s *BackupSet) Save(persistentState PersistentState, bucket []byte) error {
+	if len(s.Entries) == 0 && len(s.Skipped) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return persistentState.Set(bucket, []byte(s.ID), data)
+}
+
+// newBackupEntry returns a BackupEntry recording the state of path in fs, or
+// nil if the state cannot be recorded.
+func newBackupEntry(fs vfs.FS, path string) (*BackupEntry, error) {
+	info, err := fs.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+		return &BackupEntry{
+			Path: path,
+			Type: BackupTypeMissing,
+		}, nil
+	case err != nil:
+		return nil, err
+	}
+	switch {
+	case info.IsDir():
+		return &BackupEntry{
+			Path: path,
+			Type: BackupTypeDir,
+			Mode: info.Mode().Perm(),
+		}, nil
+	case info.Mode().IsRegular():
+		contents, err := fs.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return &BackupEntry{
+			Path:     path,
+			Type:     BackupTypeFile,
+			Mode:     info.Mode().Perm(),
+			Contents: contents,
+		}, nil
+	case info.Mode()&os.ModeType == os.ModeSymlink:
+		linkname, err := fs.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		return &BackupEntry{
+			Path:     path,
+			Type:     BackupTypeSymlink,
+			Linkname: linkname,
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// restore restores the state of e's path in fs. It returns false if the
+// path is a non-empty directory that would have to be removed.
+func (e *BackupEntry) restore(fs vfs.FS, mutator Mutator) (bool, error) {
+	info, err := fs.Lstat(e.Path)
+	switch {
+	case os.IsNotExist(err):
+		info = nil
+	case err != nil:
+		return false, err
+	}
+	switch e.Type {
+	case BackupTypeDir:
+		if info != nil && info.IsDir() {
+			if info.Mode().Perm() == e.Mode {
+				return true, nil
+			}
+			return true, mutator.Chmod(e.Path, e.Mode)
+		}
+		if info != nil {
+			if err := mutator.RemoveAll(e.Path); err != nil {
+				return false, err
+			}
+		}
+		if err := mutator.Mkdir(e.Path, e.Mode); err != nil {
+			return false, err
+		}
+		// Mkdir is subject to the umask, so set the permissions explicitly.
+		return true, mutator.Chmod(e.Path, e.Mode)
+	case BackupTypeFile:
+		var currData []byte
+		if info != nil && info.Mode().IsRegular() {
+			currData, err = fs.ReadFile(e.Path)
+			if err != nil {
+				return false, err
+			}
+			if bytes.Equal(currData, e.Contents) {
+				if info.Mode().Perm() == e.Mode {
+					return true, nil
+				}
+				return true, mutator.Chmod(e.Path, e.Mode)
+			}
+			// Make read-only files writable so that they can be replaced.
+			if perm := info.Mode().Perm(); perm&0o200 == 0 {
+				if err := mutator.Chmod(e.Path, perm|0o200); err != nil {
+					return false, err
+				}
+			}
+		} else if info != nil {
+			if removed, err := removeCreated(fs, mutator, e.Path, info); err != nil || !removed {
+				return false, err
+			}
+		}
+		return true, mutator.WriteFile(e.Path, e.Contents, e.Mode, currData)
+	case BackupTypeMissing:
+		if info == nil {
+			return true, nil
+		}
+		return removeCreated(fs, mutator, e.Path, info)
+	case BackupTypeSymlink:
+		if info != nil && info.Mode()&os.ModeType == os.ModeSymlink {
+			if linkname, err := fs.Readlink(e.Path); err == nil && linkname == e.Linkname {
+				return true, nil
+			}
+		} else if info != nil {
+			if removed, err := removeCreated(fs, mutator, e.Path, info); err != nil || !removed {
+				return false, err
+			}
+		}
+		return true, mutator.WriteSymlink(e.Linkname, e.Path)
+	default:
+		return false, fmt.Errorf("%s: unknown backup type %q", e.Path, e.Type)
+	}
+}
+
+// removeCreated removes path, which was created by an apply and has info, with
+// mutator. Directories are only removed if they are empty, so that anything
+// added to them since the apply is kept. It returns false if path was not
+// removed.
+func removeCreated(fs vfs.FS, mutator Mutator, path string, info os.FileInfo) (bool, error) {
+	if info.IsDir() {
+		infos, err := fs.ReadDir(path)
+		if err != nil {
+			return false, err
+		}
+		if len(infos) != 0 {
+			return false, nil
+		}
+	}
+	return true, mutator.RemoveAll(path)
+}
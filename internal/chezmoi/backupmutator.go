@@ -0,0 +1,157 @@
+package chezmoi
+
+import (
+	"os"
+	"os/exec"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// A BackupMutator wraps a Mutator and records the state of each path in a
+// BackupSet before it is first changed.
+type BackupMutator struct {
+	m         Mutator
+	fs        vfs.FS
+	backupSet *BackupSet
+	maxSize   int64
+	backedUp  map[string]bool
+}
+
+// NewBackupMutator returns a new BackupMutator that records the state of
+// paths in fs in backupSet. Files larger than maxSize bytes are not recorded
+// and are added to backupSet's skipped paths instead. A maxSize of zero or
+// less means that files of any size are recorded.
+func NewBackupMutator(m Mutator, fs vfs.FS, backupSet *BackupSet, maxSize int64) *BackupMutator {
+	return &BackupMutator{
+		m:         m,
+		fs:        fs,
+		backupSet: backupSet,
+		maxSize:   maxSize,
+		backedUp:  make(map[string]bool),
+	}
+}
+
+// Chmod implements Mutator.Chmod.
+func (m *BackupMutator) Chmod(name string, mode os.FileMode) error {
+	if err := m.backup(name); err != nil {
+		return err
+	}
+	return m.m.Chmod(name, mode)
+}
+
+// Chown implements Mutator.Chown.
+func (m *BackupMutator) Chown(name string, uid, gid int) error {
+	if err := m.backup(name); err != nil {
+		return err
+	}
+	return m.m.Chown(name, uid, gid)
+}
+
+// IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
+func (m *BackupMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	return m.m.IdempotentCmdOutput(cmd)
+}
+
+// Mkdir implements Mutator.Mkdir.
+func (m *BackupMutator) Mkdir(name string, perm os.FileMode) error {
+	if err := m.backup(name); err != nil {
+		return err
+	}
+	return m.m.Mkdir(name, perm)
+}
+
+// RemoveAll implements Mutator.RemoveAll.
+func (m *BackupMutator) RemoveAll(name string) error {
+	if err := m.backupAll(name); err != nil {
+		return err
+	}
+	return m.m.RemoveAll(name)
+}
+
+// Rename implements Mutator.Rename.
+func (m *BackupMutator) Rename(oldpath, newpath string) error {
+	if err := m.backupAll(oldpath); err != nil {
+		return err
+	}
+	if err := m.backupAll(newpath); err != nil {
+		return err
+	}
+	return m.m.Rename(oldpath, newpath)
+}
+
+// RunCmd implements Mutator.RunCmd.
+func (m *BackupMutator) RunCmd(cmd *exec.Cmd) error {
+	return m.m.RunCmd(cmd)
+}
+
+// Stat implements Mutator.Stat.
+func (m *BackupMutator) Stat(name string) (os.FileInfo, error) {
+	return m.m.Stat(name)
+}
+
+// WriteFile implements Mutator.WriteFile.
+func (m *BackupMutator) WriteFile(name string, data []byte, perm os.FileMode, currData []byte) error {
+	if err := m.backup(name); err != nil {
+		return err
+	}
+	return m.m.WriteFile(name, data, perm, currData)
+}
+
+// WriteSymlink implements Mutator.WriteSymlink.
+func (m *BackupMutator) WriteSymlink(oldname, newname string) error {
+	if err := m.backupAll(newname); err != nil {
+		return err
+	}
+	return m.m.WriteSymlink(oldname, newname)
+}
+
+// backup records the state of name, if it has not already been recorded.
+func (m *BackupMutator) backup(name string) error {
+	if m.backedUp[name] {
+		return nil
+	}
+	if m.maxSize > 0 {
+		if info, err := m.fs.Lstat(name); err == nil && info.Mode().IsRegular() && info.Size() > m.maxSize {
+			m.backedUp[name] = true
+			m.backupSet.Skipped = append(m.backupSet.Skipped, name)
+			return nil
+		}
+	}
+	backupEntry, err := newBackupEntry(m.fs, name)
+	if err != nil {
+		return err
+	}
+	m.backedUp[name] = true
+	if backupEntry != nil {
+		m.backupSet.Entries = append(m.backupSet.Entries, backupEntry)
+	}
+	return nil
+}
+
+// backupAll records the state of name and, if name is a directory, everything
+// in it. Entries are recorded children first so that restoring them in reverse
+// order recreates parent directories before their children.
+func (m *BackupMutator) backupAll(name string) error {
+	var names []string
+	if err := vfs.Walk(m.fs, name, func(path string, info os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err):
+			return nil
+		case err != nil:
+			return err
+		}
+		names = append(names, path)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return m.backup(name)
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		if err := m.backup(names[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package chezmoi
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+var _ Mutator = &BackupMutator{}
+
+func TestBackupMutator(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc": "# edited .bashrc\n",
+			".dir": map[string]interface{}{
+				"file": "# contents of .dir/file\n",
+				"subdir": &vfst.Dir{
+					Perm: 0o700,
+					Entries: map[string]interface{}{
+						"file": "# contents of .dir/subdir/file\n",
+					},
+				},
+			},
+			".profile": &vfst.File{
+				Perm:     0o600,
+				Contents: []byte("# contents of .profile\n"),
+			},
+		},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"dot_bashrc":     "# contents of .bashrc\n",
+			"dot_inputrc":    "# contents of .inputrc\n",
+			"dot_profile":    "# contents of .profile\n",
+			"exact_dot_dir/": &vfst.Dir{Perm: 0o755},
+			"dot_new/file":   "# contents of .new/file\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	applyOptions := &ApplyOptions{
+		DestDir: ts.DestDir,
+		Ignore:  ts.TargetIgnore.Match,
+		Stdout:  os.Stdout,
+		Umask:   0o22,
+	}
+	backupSet := NewBackupSet(time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC))
+	assert.Equal(t, "20200102T030405.000000006Z", backupSet.ID)
+	require.NoError(t, ts.Apply(fs, NewBackupMutator(NewFSMutator(fs), fs, backupSet, 0), false, applyOptions))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestContentsString("# contents of .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.dir/file",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.inputrc",
+			vfst.TestContentsString("# contents of .inputrc\n"),
+		),
+		vfst.TestPath("/home/user/.new/file",
+			vfst.TestContentsString("# contents of .new/file\n"),
+		),
+		vfst.TestPath("/home/user/.profile",
+			vfst.TestModePerm(0o644),
+		),
+	)
+
+	keptPaths, err := backupSet.Restore(fs, NewFSMutator(fs))
+	require.NoError(t, err)
+	assert.Empty(t, keptPaths)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestContentsString("# edited .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.dir/file",
+			vfst.TestContentsString("# contents of .dir/file\n"),
+		),
+		vfst.TestPath("/home/user/.dir/subdir",
+			vfst.TestIsDir,
+			vfst.TestModePerm(0o700),
+		),
+		vfst.TestPath("/home/user/.dir/subdir/file",
+			vfst.TestContentsString("# contents of .dir/subdir/file\n"),
+		),
+		vfst.TestPath("/home/user/.inputrc",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.new",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.profile",
+			vfst.TestModePerm(0o600),
+		),
+	)
+}
+
+func TestBackupMutatorMaxSize(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".large": "# contents of .large\n",
+			".small": "#\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	backupSet := NewBackupSet(time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC))
+	m := NewBackupMutator(NullMutator{}, fs, backupSet, 8)
+	require.NoError(t, m.WriteFile("/home/user/.large", nil, 0o644, []byte("# contents of .large\n")))
+	require.NoError(t, m.WriteFile("/home/user/.small", nil, 0o644, []byte("#\n")))
+	assert.Equal(t, []*BackupEntry{
+		{
+			Path:     "/home/user/.small",
+			Type:     BackupTypeFile,
+			Mode:     0o644,
+			Contents: []byte("#\n"),
+		},
+	}, backupSet.Entries)
+	assert.Equal(t, []string{"/home/user/.large"}, backupSet.Skipped)
+}
+
+func TestBackupSets(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.config/chezmoi": &vfst.Dir{Perm: 0o755},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	persistentState, err := NewBoltPersistentState(fs, "/home/user/.config/chezmoi/chezmoistate.boltdb", nil)
+	require.NoError(t, err)
+	defer persistentState.Close()
+
+	bucket := []byte("backup")
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		backupSet := NewBackupSet(createdAt.Add(time.Duration(i) * time.Hour))
+		if i != 0 {
+			backupSet.Entries = append(backupSet.Entries, &BackupEntry{
+				Path: "/home/user/.bashrc",
+				Type: BackupTypeMissing,
+			})
+		}
+		require.NoError(t, backupSet.Save(persistentState, bucket))
+	}
+
+	// Empty backup sets are not saved.
+	backupSets, err := LoadBackupSets(persistentState, bucket)
+	require.NoError(t, err)
+	require.Len(t, backupSets, 3)
+	assert.Equal(t, "20200102T040405.000000000Z", backupSets[0].ID)
+	assert.Equal(t, "20200102T060405.000000000Z", backupSets[2].ID)
+
+	require.NoError(t, PruneBackupSets(persistentState, bucket, 2))
+	backupSets, err = LoadBackupSets(persistentState, bucket)
+	require.NoError(t, err)
+	require.Len(t, backupSets, 2)
+	assert.Equal(t, "20200102T050405.000000000Z", backupSets[0].ID)
+
+	require.NoError(t, backupSets[1].Delete(persistentState, bucket))
+	backupSets, err = LoadBackupSets(persistentState, bucket)
+	require.NoError(t, err)
+	require.Len(t, backupSets, 1)
+	assert.Equal(t, "20200102T050405.000000000Z", backupSets[0].ID)
+}
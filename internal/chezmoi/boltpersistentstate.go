@@ -1,19 +1,34 @@
 package chezmoi
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 
 	vfs "github.com/twpayne/go-vfs"
 	bolt "go.etcd.io/bbolt"
 )
 
+// schemaBucket records which migrations have been applied. Its keys are the
+// names of migrated buckets and its values are their schema versions.
+var (
+	schemaBucket             = []byte("schema")
+	scriptStateSchemaVersion = []byte("2")
+)
+
 // A BoltPersistentState is a state persisted with bolt.
 type BoltPersistentState struct {
 	fs      vfs.FS
 	path    string
 	options *bolt.Options
 	db      *bolt.DB
+	// migrated contains records added by migrations that could not be
+	// written because db is read-only, indexed by bucket and key.
+	migrated map[string]map[string][]byte
 }
 
 // NewBoltPersistentState returns a new BoltPersistentState.
@@ -63,13 +78,44 @@ func (b *BoltPersistentState) Delete(bucket, key []byte) error {
 	})
 }
 
+// ForEach calls fn for each key and value in bucket. If bucket does not exist
+// then ForEach does nothing.
+func (b *BoltPersistentState) ForEach(bucket []byte, fn func(k, v []byte) error) error {
+	if b.db == nil {
+		return nil
+	}
+	if err := b.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(append([]byte(nil), k...), append([]byte(nil), v...))
+		})
+	}); err != nil {
+		return err
+	}
+	migratedValues := b.migrated[string(bucket)]
+	keys := make([]string, 0, len(migratedValues))
+	for k := range migratedValues {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn([]byte(k), append([]byte(nil), migratedValues[k]...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Get returns the value associated with key in bucket.
 func (b *BoltPersistentState) Get(bucket, key []byte) ([]byte, error) {
 	var value []byte
 	if b.db == nil {
 		return value, nil
 	}
-	return value, b.db.View(func(tx *bolt.Tx) error {
+	if err := b.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucket)
 		if b == nil {
 			return nil
@@ -80,6 +126,73 @@ func (b *BoltPersistentState) Get(bucket, key []byte) ([]byte, error) {
 			copy(value, v)
 		}
 		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if value == nil {
+		if v, ok := b.migrated[string(bucket)][string(key)]; ok {
+			value = append([]byte(nil), v...)
+		}
+	}
+	return value, nil
+}
+
+// MigrateScriptState migrates the script state in bucket. Earlier versions
+// recorded run_once_ scripts by their target name and the SHA256 of their
+// contents, which is now how run_onchange_ scripts are recorded. For each such
+// record written by a run_once_ script, MigrateScriptState adds a record keyed
+// by the target name alone so that the script is not run again. Once bucket
+// has been migrated, MigrateScriptState records its schema version and later
+// calls do nothing. If b is read-only then the added records are kept in memory
+// instead, so that they are still visible to Get and ForEach.
+func (b *BoltPersistentState) MigrateScriptState(bucket []byte) error {
+	if b.db == nil {
+		return nil
+	}
+	var migrated bool
+	if err := b.db.View(func(tx *bolt.Tx) error {
+		if sb := tx.Bucket(schemaBucket); sb != nil {
+			migrated = bytes.Equal(sb.Get(bucket), scriptStateSchemaVersion)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if migrated {
+		return nil
+	}
+	if b.options != nil && b.options.ReadOnly {
+		return b.db.View(func(tx *bolt.Tx) error {
+			newValues, err := scriptStateMigrations(tx.Bucket(bucket))
+			if err != nil || len(newValues) == 0 {
+				return err
+			}
+			if b.migrated == nil {
+				b.migrated = make(map[string]map[string][]byte)
+			}
+			b.migrated[string(bucket)] = newValues
+			return nil
+		})
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		sb, err := tx.CreateBucketIfNotExists(schemaBucket)
+		if err != nil {
+			return err
+		}
+		if err := sb.Put(bucket, scriptStateSchemaVersion); err != nil {
+			return err
+		}
+		b := tx.Bucket(bucket)
+		newValues, err := scriptStateMigrations(b)
+		if err != nil {
+			return err
+		}
+		for k, v := range newValues {
+			if err := b.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 }
 
@@ -116,3 +229,43 @@ func (b *BoltPersistentState) openDB() error {
 	b.db = db
 	return err
 }
+
+// scriptStateMigrations returns the records that MigrateScriptState adds to b,
+// indexed by key. b may be nil.
+func scriptStateMigrations(b *bolt.Bucket) (map[string][]byte, error) {
+	newValues := make(map[string][]byte)
+	if b == nil {
+		return newValues, nil
+	}
+	if err := b.ForEach(func(k, v []byte) error {
+		i := bytes.LastIndexByte(k, ':')
+		if i == -1 || !isSHA256HexString(k[i+1:]) {
+			return nil
+		}
+		// Leave records that cannot be parsed unchanged.
+		var scriptState ScriptState
+		if json.Unmarshal(v, &scriptState) != nil {
+			return nil
+		}
+		if !ParseScriptAttributes(filepath.Base(scriptState.Name)).Once {
+			return nil
+		}
+		if b.Get(k[:i]) != nil {
+			return nil
+		}
+		newValues[string(k[:i])] = append([]byte(nil), v...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return newValues, nil
+}
+
+// isSHA256HexString returns true if s is a hex-encoded SHA256 sum.
+func isSHA256HexString(s []byte) bool {
+	if len(s) != hex.EncodedLen(sha256.Size) {
+		return false
+	}
+	_, err := hex.DecodeString(string(s))
+	return err == nil
+}
@@ -61,6 +61,13 @@ func TestBoltPersistentState(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, value, actualValue)
 
+	values := make(map[string]string)
+	require.NoError(t, b.ForEach(bucket, func(k, v []byte) error {
+		values[string(k)] = string(v)
+		return nil
+	}))
+	assert.Equal(t, map[string]string{string(key): string(value)}, values)
+
 	require.NoError(t, b.Close())
 
 	b, err = NewBoltPersistentState(fs, path, nil)
@@ -118,3 +125,124 @@ func TestBoltPersistentStateReadOnly(t *testing.T) {
 	require.NoError(t, b.Close())
 	require.NoError(t, c.Close())
 }
+
+func TestBoltPersistentStateMigrateScriptState(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.config/chezmoi": &vfst.Dir{Perm: 0o755},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	var (
+		path     = "/home/user/.config/chezmoi/chezmoistate.boltdb"
+		bucket   = []byte("script")
+		hash     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		onceData = []byte(`{"name":"dir/run_once_foo","executedAt":"2020-01-01T00:00:00Z"}`)
+	)
+
+	b, err := NewBoltPersistentState(fs, path, nil)
+	require.NoError(t, err)
+	defer b.Close()
+
+	for key, value := range map[string][]byte{
+		"dir/foo:" + hash: onceData,
+		"bar:" + hash:     []byte(`{"name":"run_onchange_bar","executedAt":"2020-01-01T00:00:00Z"}`),
+		"baz:" + hash:     []byte(`{"name":"run_once_baz","executedAt":"2020-01-01T00:00:00Z"}`),
+		"baz":             []byte("baz"),
+		"qux:notahash":    []byte(`{"name":"run_once_qux","executedAt":"2020-01-01T00:00:00Z"}`),
+	} {
+		require.NoError(t, b.Set(bucket, []byte(key), value))
+	}
+
+	require.NoError(t, b.MigrateScriptState(bucket))
+
+	for _, tc := range []struct {
+		key           string
+		expectedValue []byte
+	}{
+		{
+			key:           "dir/foo",
+			expectedValue: onceData,
+		},
+		{
+			key:           "dir/foo:" + hash,
+			expectedValue: onceData,
+		},
+		{
+			key: "bar",
+		},
+		{
+			key:           "baz",
+			expectedValue: []byte("baz"),
+		},
+		{
+			key: "qux",
+		},
+	} {
+		actualValue, err := b.Get(bucket, []byte(tc.key))
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectedValue, actualValue, tc.key)
+	}
+
+	actualSchemaVersion, err := b.Get(schemaBucket, bucket)
+	require.NoError(t, err)
+	assert.Equal(t, scriptStateSchemaVersion, actualSchemaVersion)
+
+	// Test that records are not migrated again once the bucket has been
+	// migrated.
+	require.NoError(t, b.Set(bucket, []byte("quux:"+hash), []byte(`{"name":"run_once_quux","executedAt":"2020-01-01T00:00:00Z"}`)))
+	require.NoError(t, b.MigrateScriptState(bucket))
+	actualValue, err := b.Get(bucket, []byte("quux"))
+	require.NoError(t, err)
+	assert.Nil(t, actualValue)
+}
+
+func TestBoltPersistentStateMigrateScriptStateReadOnly(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.config/chezmoi": &vfst.Dir{Perm: 0o755},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	var (
+		path     = "/home/user/.config/chezmoi/chezmoistate.boltdb"
+		bucket   = []byte("script")
+		hash     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		onceData = []byte(`{"name":"run_once_foo","executedAt":"2020-01-01T00:00:00Z"}`)
+	)
+
+	b, err := NewBoltPersistentState(fs, path, nil)
+	require.NoError(t, err)
+	require.NoError(t, b.Set(bucket, []byte("foo:"+hash), onceData))
+	require.NoError(t, b.Close())
+
+	r, err := NewBoltPersistentState(fs, path, &bolt.Options{
+		ReadOnly: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, r.MigrateScriptState(bucket))
+
+	actualValue, err := r.Get(bucket, []byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, onceData, actualValue)
+
+	var keys []string
+	require.NoError(t, r.ForEach(bucket, func(k, v []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	}))
+	assert.Equal(t, []string{"foo:" + hash, "foo"}, keys)
+
+	actualSchemaVersion, err := r.Get(schemaBucket, bucket)
+	require.NoError(t, err)
+	assert.Nil(t, actualSchemaVersion)
+	require.NoError(t, r.Close())
+
+	// Test that the in-memory migration is not persisted.
+	b, err = NewBoltPersistentState(fs, path, nil)
+	require.NoError(t, err)
+	defer b.Close()
+	actualValue, err = b.Get(bucket, []byte("foo"))
+	require.NoError(t, err)
+	assert.Nil(t, actualValue)
+}
@@ -3,33 +3,86 @@ package chezmoi
 import (
 	"archive/tar"
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"syscall"
 
+	"github.com/pelletier/go-toml"
 	vfs "github.com/twpayne/go-vfs"
+	"gopkg.in/yaml.v2"
 )
 
 // Suffixes and prefixes.
 const (
+	afterPrefix      = "after_"
+	beforePrefix     = "before_"
+	createPrefix     = "create_"
 	dotPrefix        = "dot_"
 	emptyPrefix      = "empty_"
 	encryptedPrefix  = "encrypted_"
 	exactPrefix      = "exact_"
 	executablePrefix = "executable_"
+	literalPrefix    = "literal_"
+	modifyPrefix     = "modify_"
+	nameTmplPrefix   = "nametmpl_"
+	onChangePrefix   = "onchange_"
 	oncePrefix       = "once_"
 	privatePrefix    = "private_"
+	readOnlyPrefix   = "readonly_"
 	runPrefix        = "run_"
 	symlinkPrefix    = "symlink_"
+	literalSuffix    = ".literal"
 	TemplateSuffix   = ".tmpl"
 )
 
+// attributePrefixes are all prefixes that have a meaning in a source name.
+var attributePrefixes = []string{
+	afterPrefix,
+	beforePrefix,
+	createPrefix,
+	dotPrefix,
+	emptyPrefix,
+	encryptedPrefix,
+	exactPrefix,
+	executablePrefix,
+	literalPrefix,
+	modifyPrefix,
+	nameTmplPrefix,
+	onChangePrefix,
+	oncePrefix,
+	privatePrefix,
+	readOnlyPrefix,
+	runPrefix,
+	symlinkPrefix,
+}
+
+// formatDecoders maps file extensions to decoders.
+var formatDecoders = map[string]func([]byte, interface{}) error{
+	"json": json.Unmarshal,
+	"toml": toml.Unmarshal,
+	"yaml": yaml.Unmarshal,
+}
+
+// A Mode is a way of applying files.
+type Mode string
+
+// Modes.
+const (
+	ModeFile    Mode = "file"
+	ModeSymlink Mode = "symlink"
+)
+
 // A PersistentState is an interface to a persistent state.
 type PersistentState interface {
 	Close() error
 	Delete(bucket, key []byte) error
+	ForEach(bucket []byte, fn func(k, v []byte) error) error
 	Get(bucket, key []byte) ([]byte, error)
 	Set(bucket, key, value []byte) error
 }
@@ -38,39 +91,146 @@ type PersistentState interface {
 type ApplyOptions struct {
 	DestDir           string
 	DryRun            bool
+	EntryStateBucket  []byte
 	Ignore            func(string) bool
+	Interpreters      map[string]*Interpreter
+	Mode              Mode
 	PersistentState   PersistentState
+	PrivilegedDirs    map[string]string
+	RecordScript      func(path string, contents []byte, interpreter *Interpreter, env []string)
 	Remove            bool
+	ScriptEnv         []string
 	ScriptStateBucket []byte
 	Stdout            io.Writer
 	Umask             os.FileMode
 	Verbose           bool
 }
 
+// TargetPath returns the path of targetName, taking into account any
+// privileged directories.
+func (o *ApplyOptions) TargetPath(targetName string) string {
+	return joinTargetPath(o.DestDir, o.PrivilegedDirs, targetName)
+}
+
 // An Entry is either a Dir, a File, or a Symlink.
 type Entry interface {
 	AppendAllEntries(allEntries []Entry) []Entry
 	Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error
-	ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error)
+	ConcreteValue(ignore func(string) bool, sourceDir func(Entry) string, umask os.FileMode, recursive bool) (interface{}, error)
 	Evaluate(ignore func(string) bool) error
 	SourceName() string
 	TargetName() string
 	archive(w *tar.Writer, ignore func(string) bool, headerTemplate *tar.Header, umask os.FileMode) error
 }
 
+// ApplyEntries applies entries. Scripts in entries and their descendants with
+// the before attribute are run before any other entry is applied, and scripts
+// with the after attribute are run after all other entries have been applied.
+// Within each phase, scripts are run in order of their target names.
+func ApplyEntries(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions, entries []Entry) error {
+	var beforeScripts, afterScripts []*Script
+	for _, entry := range entries {
+		beforeScripts, afterScripts = appendPhaseScripts(beforeScripts, afterScripts, entry, applyOptions.Ignore)
+	}
+	sortScripts(beforeScripts)
+	sortScripts(afterScripts)
+
+	for _, s := range beforeScripts {
+		if err := s.Apply(fs, mutator, follow, applyOptions); err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if isPhaseScript(entry) {
+			continue
+		}
+		if err := entry.Apply(fs, mutator, follow, applyOptions); err != nil {
+			return err
+		}
+	}
+	for _, s := range afterScripts {
+		if err := s.Apply(fs, mutator, follow, applyOptions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type parsedSourceFilePath struct {
 	dirAttributes    []DirAttributes
 	fileAttributes   *FileAttributes
 	scriptAttributes *ScriptAttributes
 }
 
-// dirNames returns the dir names from dirAttributes.
-func dirNames(dirAttributes []DirAttributes) []string {
-	dns := make([]string, len(dirAttributes))
-	for i, da := range dirAttributes {
-		dns[i] = da.Name
+// IsNotExist returns true if err indicates that a path does not exist,
+// including when one of its parents is not a directory.
+func IsNotExist(err error) bool {
+	return os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR)
+}
+
+// SortedEntries returns entries and all of their descendants, including
+// scripts, ordered by target name. Ignored entries and their descendants are
+// excluded.
+func SortedEntries(entries []Entry, ignore func(string) bool) []Entry {
+	var sortedEntries []Entry
+	for _, entry := range entries {
+		sortedEntries = appendEntryAndDescendants(sortedEntries, entry, ignore)
+	}
+	sort.Slice(sortedEntries, func(i, j int) bool {
+		return sortedEntries[i].TargetName() < sortedEntries[j].TargetName()
+	})
+	return sortedEntries
+}
+
+// appendEntryAndDescendants appends entry and all of its descendants that are
+// not ignored to entries.
+func appendEntryAndDescendants(entries []Entry, entry Entry, ignore func(string) bool) []Entry {
+	if ignore(entry.TargetName()) {
+		return entries
+	}
+	entries = append(entries, entry)
+	if dir, ok := entry.(*Dir); ok {
+		for _, childEntry := range dir.Entries {
+			entries = appendEntryAndDescendants(entries, childEntry, ignore)
+		}
+	}
+	return entries
+}
+
+// formatNamePrefix returns the source name of the target name name, without
+// any attribute prefixes.
+func formatNamePrefix(name string) string {
+	switch {
+	case strings.HasPrefix(name, "."):
+		return dotPrefix + strings.TrimPrefix(name, ".")
+	case hasAttributePrefix(name):
+		return literalPrefix + name
+	default:
+		return name
+	}
+}
+
+// formatNameSuffix returns sourceName with the suffixes needed to mark it as a
+// template, if template is true, and to preserve any suffixes already in name.
+func formatNameSuffix(sourceName, name string, template bool) string {
+	if strings.HasSuffix(name, TemplateSuffix) || strings.HasSuffix(name, literalSuffix) {
+		sourceName += literalSuffix
+	}
+	if template {
+		sourceName += TemplateSuffix
+	}
+	return sourceName
+}
+
+// hasAttributePrefix returns true if name starts with a prefix that would be
+// interpreted as an attribute in a source name.
+func hasAttributePrefix(name string) bool {
+	for _, prefix := range attributePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
 	}
-	return dns
+	return false
 }
 
 // isEmpty returns true if b should be considered empty.
@@ -78,6 +238,31 @@ func isEmpty(b []byte) bool {
 	return len(bytes.TrimSpace(b)) == 0
 }
 
+// normalizeMaps returns value with all map[interface{}]interface{}s, as
+// returned by the YAML decoder, converted to map[string]interface{}s.
+func normalizeMaps(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[fmt.Sprint(k)] = normalizeMaps(v)
+		}
+		return result
+	case map[string]interface{}:
+		for k, v := range value {
+			value[k] = normalizeMaps(v)
+		}
+		return value
+	case []interface{}:
+		for i, v := range value {
+			value[i] = normalizeMaps(v)
+		}
+		return value
+	default:
+		return value
+	}
+}
+
 // parseDirNameComponents parses multiple directory name components.
 func parseDirNameComponents(components []string) []DirAttributes {
 	das := []DirAttributes{}
@@ -88,6 +273,30 @@ func parseDirNameComponents(components []string) []DirAttributes {
 	return das
 }
 
+// parseNamePrefix returns the target name of name, which is a source name
+// without any attribute prefixes.
+func parseNamePrefix(name string) string {
+	switch {
+	case strings.HasPrefix(name, literalPrefix):
+		return strings.TrimPrefix(name, literalPrefix)
+	case strings.HasPrefix(name, dotPrefix):
+		return "." + strings.TrimPrefix(name, dotPrefix)
+	default:
+		return name
+	}
+}
+
+// parseNameSuffix returns name without its suffixes and whether name has the
+// template suffix.
+func parseNameSuffix(name string) (string, bool) {
+	template := false
+	if strings.HasSuffix(name, TemplateSuffix) {
+		name = strings.TrimSuffix(name, TemplateSuffix)
+		template = true
+	}
+	return strings.TrimSuffix(name, literalSuffix), template
+}
+
 // parseSourceFilePath parses a single source file path.
 func parseSourceFilePath(path string) parsedSourceFilePath {
 	components := splitPathList(path)
@@ -117,9 +326,79 @@ func sortedEntryNames(entries map[string]Entry) []string {
 	return entryNames
 }
 
+// recursiveMerge recursively merges source into dest. Maps are merged and all
+// other values in source replace those in dest.
+func recursiveMerge(dest, source map[string]interface{}) {
+	for key, sourceValue := range source {
+		if sourceMap, ok := sourceValue.(map[string]interface{}); ok {
+			if destMap, ok := dest[key].(map[string]interface{}); ok {
+				recursiveMerge(destMap, sourceMap)
+				continue
+			}
+		}
+		dest[key] = sourceValue
+	}
+}
+
 func splitPathList(path string) []string {
 	if strings.HasPrefix(path, string(filepath.Separator)) {
 		path = strings.TrimPrefix(path, string(filepath.Separator))
 	}
 	return strings.Split(path, string(filepath.Separator))
 }
+
+// privilegedDirPrefix returns the longest prefix of targetName in
+// privilegedDirs.
+func privilegedDirPrefix(privilegedDirs map[string]string, targetName string) (string, bool) {
+	longestPrefix, ok := "", false
+	for prefix := range privilegedDirs {
+		if targetName != prefix && !strings.HasPrefix(targetName, prefix+string(filepath.Separator)) {
+			continue
+		}
+		if !ok || len(prefix) > len(longestPrefix) {
+			longestPrefix, ok = prefix, true
+		}
+	}
+	return longestPrefix, ok
+}
+
+// relTargetName returns the target name of targetPath, which must be in
+// destDir or in one of privilegedDirs.
+func relTargetName(fs vfs.Stater, destDir string, privilegedDirs map[string]string, targetPath string) (string, error) {
+	longestPrefix, longestDir := "", ""
+	for prefix, dir := range privilegedDirs {
+		if len(dir) <= len(longestDir) {
+			continue
+		}
+		if contains, err := vfs.Contains(fs, targetPath, dir); err != nil {
+			return "", err
+		} else if contains {
+			longestPrefix, longestDir = prefix, dir
+		}
+	}
+	if longestDir != "" {
+		relPath, err := filepath.Rel(longestDir, targetPath)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(longestPrefix, relPath), nil
+	}
+	contains, err := vfs.Contains(fs, targetPath, destDir)
+	if err != nil {
+		return "", err
+	}
+	if !contains {
+		return "", fmt.Errorf("%s: outside target directory", targetPath)
+	}
+	return filepath.Rel(destDir, targetPath)
+}
+
+// joinTargetPath returns the path of targetName in destDir, or in the
+// directory that privilegedDirs maps its subtree to.
+func joinTargetPath(destDir string, privilegedDirs map[string]string, targetName string) string {
+	prefix, ok := privilegedDirPrefix(privilegedDirs, targetName)
+	if !ok {
+		return filepath.Join(destDir, targetName)
+	}
+	return filepath.Join(privilegedDirs[prefix], strings.TrimPrefix(targetName, prefix))
+}
@@ -2,9 +2,12 @@ package chezmoi
 
 import (
 	"errors"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
 )
 
 func TestReturnTemplateError(t *testing.T) {
@@ -30,3 +33,41 @@ func TestReturnTemplateError(t *testing.T) {
 		})
 	}
 }
+
+func TestPrivilegedTargetPaths(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/etc/ssh":   &vfst.Dir{Perm: 0o755},
+		"/home/user": &vfst.Dir{Perm: 0o755},
+		"/opt/ssh":   &vfst.Dir{Perm: 0o755},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	destDir := "/home/user"
+	privilegedDirs := map[string]string{
+		"etc":                       "/etc",
+		filepath.Join("etc", "ssh"): "/opt/ssh",
+	}
+	for _, tc := range []struct {
+		targetName string
+		targetPath string
+	}{
+		{targetName: ".bashrc", targetPath: "/home/user/.bashrc"},
+		{targetName: "etcetera", targetPath: "/home/user/etcetera"},
+		{targetName: "etc", targetPath: "/etc"},
+		{targetName: filepath.Join("etc", "hosts"), targetPath: "/etc/hosts"},
+		{targetName: filepath.Join("etc", "ssh"), targetPath: "/opt/ssh"},
+		{targetName: filepath.Join("etc", "ssh", "sshd_config"), targetPath: "/opt/ssh/sshd_config"},
+	} {
+		t.Run(tc.targetName, func(t *testing.T) {
+			targetPath := filepath.FromSlash(tc.targetPath)
+			assert.Equal(t, targetPath, joinTargetPath(destDir, privilegedDirs, tc.targetName))
+			actualTargetName, err := relTargetName(fs, destDir, privilegedDirs, targetPath)
+			require.NoError(t, err)
+			assert.Equal(t, tc.targetName, actualTargetName)
+		})
+	}
+
+	_, err = relTargetName(fs, destDir, privilegedDirs, "/var/log")
+	assert.Error(t, err)
+}
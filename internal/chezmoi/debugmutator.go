@@ -26,6 +26,13 @@ func (m *DebugMutator) Chmod(name string, mode os.FileMode) error {
 	})
 }
 
+// Chown implements Mutator.Chown.
+func (m *DebugMutator) Chown(name string, uid, gid int) error {
+	return Debugf("Chown(%q, %d, %d)", []interface{}{name, uid, gid}, func() error {
+		return m.m.Chown(name, uid, gid)
+	})
+}
+
 // IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
 func (m *DebugMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
 	var output []byte
@@ -11,9 +11,10 @@ import (
 
 // DirAttributes holds attributes parsed from a source directory name.
 type DirAttributes struct {
-	Name  string
-	Exact bool
-	Perm  os.FileMode
+	Name         string
+	Exact        bool
+	NameTemplate bool
+	Perm         os.FileMode
 }
 
 // A Dir represents the target state of a directory.
@@ -22,6 +23,8 @@ type Dir struct {
 	targetName string
 	Exact      bool
 	Perm       os.FileMode
+	Owner      string
+	Group      string
 	Entries    map[string]Entry
 }
 
@@ -31,6 +34,9 @@ type dirConcreteValue struct {
 	TargetPath string        `json:"targetPath" yaml:"targetPath"`
 	Exact      bool          `json:"exact" yaml:"exact"`
 	Perm       int           `json:"perm" yaml:"perm"`
+	ReadOnly   bool          `json:"readonly" yaml:"readonly"`
+	Owner      string        `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Group      string        `json:"group,omitempty" yaml:"group,omitempty"`
 	Entries    []interface{} `json:"entries" yaml:"entries"`
 }
 
@@ -47,13 +53,20 @@ func ParseDirAttributes(sourceName string) DirAttributes {
 		name = strings.TrimPrefix(name, privatePrefix)
 		perm &= 0o700
 	}
-	if strings.HasPrefix(name, dotPrefix) {
-		name = "." + strings.TrimPrefix(name, dotPrefix)
+	if strings.HasPrefix(name, readOnlyPrefix) {
+		name = strings.TrimPrefix(name, readOnlyPrefix)
+		perm &^= 0o222
+	}
+	nameTemplate := false
+	if strings.HasPrefix(name, nameTmplPrefix) {
+		name = strings.TrimPrefix(name, nameTmplPrefix)
+		nameTemplate = true
 	}
 	return DirAttributes{
-		Name:  name,
-		Exact: exact,
-		Perm:  perm,
+		Name:         parseNamePrefix(name),
+		Exact:        exact,
+		NameTemplate: nameTemplate,
+		Perm:         perm,
 	}
 }
 
@@ -66,12 +79,13 @@ func (da DirAttributes) SourceName() string {
 	if da.Perm&os.FileMode(0o77) == os.FileMode(0) {
 		sourceName += privatePrefix
 	}
-	if strings.HasPrefix(da.Name, ".") {
-		sourceName += dotPrefix + strings.TrimPrefix(da.Name, ".")
-	} else {
-		sourceName += da.Name
+	if da.Perm&os.FileMode(0o222) == os.FileMode(0) {
+		sourceName += readOnlyPrefix
+	}
+	if da.NameTemplate {
+		sourceName += nameTmplPrefix
 	}
-	return sourceName
+	return sourceName + formatNamePrefix(da.Name)
 }
 
 // newDir returns a new directory state.
@@ -99,7 +113,7 @@ func (d *Dir) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Apply
 	if applyOptions.Ignore(d.targetName) {
 		return nil
 	}
-	targetPath := filepath.Join(applyOptions.DestDir, d.targetName)
+	targetPath := applyOptions.TargetPath(d.targetName)
 	var info os.FileInfo
 	var err error
 	if follow {
@@ -107,12 +121,39 @@ func (d *Dir) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Apply
 	} else {
 		info, err = fs.Lstat(targetPath)
 	}
+	// Entries can only be written in a read-only directory while it is
+	// temporarily writable, so only make it read-only once its entries have
+	// been applied.
+	perm := d.Perm &^ applyOptions.Umask
+	restorePerm := false
 	switch {
 	case err == nil && info.IsDir():
-		if info.Mode().Perm() != d.Perm&^applyOptions.Umask {
-			if err := mutator.Chmod(targetPath, d.Perm&^applyOptions.Umask); err != nil {
+		currPerm := info.Mode().Perm()
+		switch {
+		case perm&0o200 != 0:
+			if currPerm != perm {
+				if err := mutator.Chmod(targetPath, perm); err != nil {
+					return err
+				}
+			}
+		case currPerm&0o200 != 0:
+			restorePerm = true
+		default:
+			anyMutator := NewAnyMutator(NullMutator{})
+			if err := d.applyEntries(fs, anyMutator, follow, applyOptions, false); err != nil {
 				return err
 			}
+			switch {
+			case anyMutator.Mutated():
+				if err := mutator.Chmod(targetPath, currPerm|0o200); err != nil {
+					return err
+				}
+				restorePerm = true
+			case currPerm != perm:
+				if err := mutator.Chmod(targetPath, perm); err != nil {
+					return err
+				}
+			}
 		}
 	case err == nil:
 		if err := mutator.RemoveAll(targetPath); err != nil {
@@ -120,20 +161,49 @@ func (d *Dir) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Apply
 		}
 		fallthrough
 	case os.IsNotExist(err):
-		if err := mutator.Mkdir(targetPath, d.Perm&^applyOptions.Umask); err != nil {
+		if err := mutator.Mkdir(targetPath, perm|0o200); err != nil {
 			return err
 		}
+		restorePerm = perm&0o200 == 0
 	default:
 		return err
 	}
+	if err := d.applyEntries(fs, mutator, follow, applyOptions, true); err != nil {
+		return err
+	}
+	if restorePerm {
+		if err := mutator.Chmod(targetPath, perm); err != nil {
+			return err
+		}
+	}
+	return applyOwnership(fs, mutator, targetPath, d.Owner, d.Group)
+}
+
+// applyEntries applies d's entries and, if d is exact, removes any other
+// entries in its target. Scripts are only run if runScripts is true.
+func (d *Dir) applyEntries(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions, runScripts bool) error {
 	for _, entryName := range sortedEntryNames(d.Entries) {
-		if err := d.Entries[entryName].Apply(fs, mutator, follow, applyOptions); err != nil {
+		entry := d.Entries[entryName]
+		// Scripts that run before or after other entries are run by
+		// ApplyEntries.
+		if isPhaseScript(entry) {
+			continue
+		}
+		if _, ok := entry.(*Script); ok && !runScripts {
+			continue
+		}
+		if err := entry.Apply(fs, mutator, follow, applyOptions); err != nil {
 			return err
 		}
 	}
 	if d.Exact {
+		targetPath := applyOptions.TargetPath(d.targetName)
 		infos, err := fs.ReadDir(targetPath)
-		if err != nil {
+		switch {
+		case os.IsNotExist(err):
+			// The target does not exist yet, for example in a dry run.
+			return nil
+		case err != nil:
 			return err
 		}
 		for _, info := range infos {
@@ -152,7 +222,7 @@ func (d *Dir) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Apply
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (d *Dir) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
+func (d *Dir) ConcreteValue(ignore func(string) bool, sourceDir func(Entry) string, umask os.FileMode, recursive bool) (interface{}, error) {
 	if ignore(d.targetName) {
 		return nil, nil
 	}
@@ -170,10 +240,13 @@ func (d *Dir) ConcreteValue(ignore func(string) bool, sourceDir string, umask os
 	}
 	return &dirConcreteValue{
 		Type:       "dir",
-		SourcePath: filepath.Join(sourceDir, d.SourceName()),
+		SourcePath: filepath.Join(sourceDir(d), d.SourceName()),
 		TargetPath: d.TargetName(),
 		Exact:      d.Exact,
 		Perm:       int(d.Perm &^ umask),
+		ReadOnly:   d.ReadOnly(),
+		Owner:      d.Owner,
+		Group:      d.Group,
 		Entries:    entryConcreteValues,
 	}, nil
 }
@@ -196,6 +269,11 @@ func (d *Dir) Private() bool {
 	return d.Perm&0o77 == 0
 }
 
+// ReadOnly returns true if d is read-only.
+func (d *Dir) ReadOnly() bool {
+	return d.Perm&0o222 == 0
+}
+
 // SourceName implements Entry.SourceName.
 func (d *Dir) SourceName() string {
 	return d.sourceName
@@ -215,6 +293,9 @@ func (d *Dir) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *t
 	header.Typeflag = tar.TypeDir
 	header.Name = d.targetName + "/"
 	header.Mode = int64(d.Perm &^ umask)
+	if err := setTarHeaderOwnership(&header, d.Owner, d.Group); err != nil {
+		return err
+	}
 	if err := w.WriteHeader(&header); err != nil {
 		return err
 	}
@@ -55,6 +55,45 @@ func TestDirAttributes(t *testing.T) {
 				Perm:  0o700,
 			},
 		},
+		{
+			sourceName: "exact_private_readonly_dot_foo",
+			da: DirAttributes{
+				Name:  ".foo",
+				Exact: true,
+				Perm:  0o500,
+			},
+		},
+		{
+			sourceName: "exact_nametmpl_{{ .host }}",
+			da: DirAttributes{
+				Name:         "{{ .host }}",
+				Exact:        true,
+				NameTemplate: true,
+				Perm:         0o777,
+			},
+		},
+		{
+			sourceName: "literal_exact_foo",
+			da: DirAttributes{
+				Name: "exact_foo",
+				Perm: 0o777,
+			},
+		},
+		{
+			sourceName: "exact_literal_dot_foo",
+			da: DirAttributes{
+				Name:  "dot_foo",
+				Exact: true,
+				Perm:  0o777,
+			},
+		},
+		{
+			sourceName: "foo.tmpl",
+			da: DirAttributes{
+				Name: "foo.tmpl",
+				Perm: 0o777,
+			},
+		},
 	} {
 		t.Run(tc.sourceName, func(t *testing.T) {
 			assert.Equal(t, tc.da, ParseDirAttributes(tc.sourceName))
@@ -0,0 +1,177 @@
+package chezmoi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// An EntryState records the state of a target when chezmoi last wrote it.
+type EntryState struct {
+	ContentsSHA256 string    `json:"contentsSHA256"`
+	AppliedAt      time.Time `json:"appliedAt"`
+}
+
+// MatchesContents returns true if s records contents.
+func (s *EntryState) MatchesContents(contents []byte) bool {
+	return s.ContentsSHA256 == contentsSHA256(contents)
+}
+
+// A Drift is a target that has been modified since chezmoi last wrote it.
+type Drift struct {
+	File        *File
+	TargetPath  string
+	Contents    []byte
+	NewContents []byte
+}
+
+// FindDrift returns the files in entries and their descendants whose targets
+// in fs have been modified since their states were recorded and that would be
+// overwritten by applying them, ordered by target name.
+func FindDrift(fs vfs.FS, follow bool, applyOptions *ApplyOptions, entries []Entry) ([]*Drift, error) {
+	var drifts []*Drift
+	for _, file := range allFiles(entries) {
+		// Targets that are only created or that are modified by scripts never
+		// overwrite changes.
+		if applyOptions.Ignore(file.targetName) || file.Create || file.Modify {
+			continue
+		}
+		targetPath := applyOptions.TargetPath(file.targetName)
+		var info os.FileInfo
+		var err error
+		if follow {
+			info, err = fs.Stat(targetPath)
+		} else {
+			info, err = fs.Lstat(targetPath)
+		}
+		switch {
+		case IsNotExist(err):
+			continue
+		case err != nil:
+			return nil, err
+		case !info.Mode().IsRegular():
+			continue
+		}
+		newContents, err := file.Contents()
+		if err != nil {
+			return nil, err
+		}
+		contents, err := fs.ReadFile(targetPath)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(contents, newContents) {
+			continue
+		}
+		entryState, err := GetEntryState(applyOptions, targetPath)
+		if err != nil {
+			return nil, err
+		}
+		if entryState == nil || entryState.MatchesContents(contents) {
+			continue
+		}
+		drifts = append(drifts, &Drift{
+			File:        file,
+			TargetPath:  targetPath,
+			Contents:    contents,
+			NewContents: newContents,
+		})
+	}
+	return drifts, nil
+}
+
+// GetEntryState returns the recorded state of targetPath, or nil if there is
+// none.
+func GetEntryState(applyOptions *ApplyOptions, targetPath string) (*EntryState, error) {
+	entryStateData, err := applyOptions.PersistentState.Get(applyOptions.EntryStateBucket, []byte(targetPath))
+	if err != nil || entryStateData == nil {
+		return nil, err
+	}
+	var entryState EntryState
+	if err := json.Unmarshal(entryStateData, &entryState); err != nil {
+		return nil, err
+	}
+	return &entryState, nil
+}
+
+// RecordEntryStates records the states of the targets of the files in entries
+// and their descendants whose targets in fs match their target states. The
+// states of targets that do not exist are forgotten.
+func RecordEntryStates(fs vfs.FS, applyOptions *ApplyOptions, entries []Entry) error {
+	for _, file := range allFiles(entries) {
+		if applyOptions.Ignore(file.targetName) {
+			continue
+		}
+		targetPath := applyOptions.TargetPath(file.targetName)
+		info, err := fs.Lstat(targetPath)
+		switch {
+		case IsNotExist(err):
+			if err := applyOptions.PersistentState.Delete(applyOptions.EntryStateBucket, []byte(targetPath)); err != nil {
+				return err
+			}
+			continue
+		case err != nil:
+			return err
+		case !info.Mode().IsRegular():
+			continue
+		}
+		contents, err := fs.ReadFile(targetPath)
+		if err != nil {
+			return err
+		}
+		newContents, err := file.Contents()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(contents, newContents) {
+			continue
+		}
+		if err := SetEntryState(applyOptions, targetPath, contents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetEntryState records that chezmoi wrote contents to targetPath.
+func SetEntryState(applyOptions *ApplyOptions, targetPath string, contents []byte) error {
+	entryStateData, err := json.Marshal(&EntryState{
+		ContentsSHA256: contentsSHA256(contents),
+		AppliedAt:      time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return applyOptions.PersistentState.Set(applyOptions.EntryStateBucket, []byte(targetPath), entryStateData)
+}
+
+// allFiles returns all files in entries and their descendants, ordered by
+// target name.
+func allFiles(entries []Entry) []*File {
+	var allEntries []Entry
+	for _, entry := range entries {
+		allEntries = entry.AppendAllEntries(allEntries)
+	}
+	var files []*File
+	for _, entry := range allEntries {
+		if file, ok := entry.(*File); ok {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].targetName < files[j].targetName
+	})
+	return files
+}
+
+// contentsSHA256 returns the hex-encoded SHA256 of contents.
+func contentsSHA256(contents []byte) string {
+	contentsSHA256Arr := sha256.Sum256(contents)
+	return hex.EncodeToString(contentsSHA256Arr[:])
+}
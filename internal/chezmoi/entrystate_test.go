@@ -0,0 +1,93 @@
+package chezmoi
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestEntryStates(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"dot_bashrc":         "# contents of .bashrc\n",
+			"dot_dir/file":       "# contents of .dir/file\n",
+			"create_dot_inputrc": "# contents of .inputrc\n",
+			"dot_profile":        "# contents of .profile\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	persistentState, err := NewBoltPersistentState(fs, "/home/user/.config/chezmoi/chezmoistate.boltdb", nil)
+	require.NoError(t, err)
+	defer persistentState.Close()
+
+	populate := func() *TargetState {
+		ts := NewTargetState(
+			WithDestDir("/home/user"),
+			WithSourceDir("/home/user/.local/share/chezmoi"),
+		)
+		require.NoError(t, ts.Populate(fs, nil))
+		return ts
+	}
+
+	ts := populate()
+	applyOptions := &ApplyOptions{
+		DestDir:          ts.DestDir,
+		EntryStateBucket: []byte("entryState"),
+		Ignore:           ts.TargetIgnore.Match,
+		PersistentState:  persistentState,
+		Stdout:           os.Stdout,
+		Umask:            0o22,
+	}
+	drifts, err := FindDrift(fs, false, applyOptions, ts.AllEntries())
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+	require.NoError(t, ts.Apply(fs, NewFSMutator(fs), false, applyOptions))
+	require.NoError(t, RecordEntryStates(fs, applyOptions, ts.AllEntries()))
+
+	// Modify targets, and change the source state of some of them.
+	for path, contents := range map[string]string{
+		"/home/user/.bashrc":                                 "# edited .bashrc\n",
+		"/home/user/.dir/file":                               "# edited .dir/file\n",
+		"/home/user/.inputrc":                                "# edited .inputrc\n",
+		"/home/user/.profile":                                "# edited .profile\n",
+		"/home/user/.local/share/chezmoi/dot_bashrc":         "# new contents of .bashrc\n",
+		"/home/user/.local/share/chezmoi/dot_dir/file":       "# edited .dir/file\n",
+		"/home/user/.local/share/chezmoi/create_dot_inputrc": "# new contents of .inputrc\n",
+		"/home/user/.local/share/chezmoi/dot_profile":        "# new contents of .profile\n",
+	} {
+		require.NoError(t, fs.WriteFile(path, []byte(contents), 0o644))
+	}
+	require.NoError(t, fs.Remove("/home/user/.profile"))
+
+	ts = populate()
+	drifts, err = FindDrift(fs, false, applyOptions, ts.AllEntries())
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+	assert.Equal(t, ".bashrc", drifts[0].File.TargetName())
+	assert.Equal(t, "/home/user/.bashrc", drifts[0].TargetPath)
+	assert.Equal(t, []byte("# edited .bashrc\n"), drifts[0].Contents)
+	assert.Equal(t, []byte("# new contents of .bashrc\n"), drifts[0].NewContents)
+
+	// Targets that are not changed by apply keep their recorded state, and the
+	// states of missing targets are forgotten.
+	require.NoError(t, RecordEntryStates(fs, applyOptions, ts.AllEntries()))
+	drifts, err = FindDrift(fs, false, applyOptions, ts.AllEntries())
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+	assert.Equal(t, ".bashrc", drifts[0].File.TargetName())
+	entryState, err := GetEntryState(applyOptions, "/home/user/.profile")
+	require.NoError(t, err)
+	assert.Nil(t, entryState)
+
+	// Once the drift is overwritten, there is no more drift.
+	require.NoError(t, ts.Apply(fs, NewFSMutator(fs), false, applyOptions))
+	require.NoError(t, RecordEntryStates(fs, applyOptions, ts.AllEntries()))
+	drifts, err = FindDrift(fs, false, applyOptions, ts.AllEntries())
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+}
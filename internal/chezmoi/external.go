@@ -0,0 +1,422 @@
+package chezmoi
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// An ExternalType is a type of external.
+type ExternalType string
+
+// External types.
+const (
+	ExternalTypeArchive ExternalType = "archive"
+	ExternalTypeFile    ExternalType = "file"
+	ExternalTypeGitRepo ExternalType = "git-repo"
+)
+
+// An External is a target populated from a URL.
+type External struct {
+	Type            ExternalType `json:"type" toml:"type" yaml:"type"`
+	URL             string       `json:"url" toml:"url" yaml:"url"`
+	Executable      bool         `json:"executable" toml:"executable" yaml:"executable"`
+	Exact           bool         `json:"exact" toml:"exact" yaml:"exact"`
+	Format          string       `json:"format" toml:"format" yaml:"format"`
+	StripComponents int          `json:"stripComponents" toml:"stripComponents" yaml:"stripComponents"`
+	Include         []string     `json:"include" toml:"include" yaml:"include"`
+	Exclude         []string     `json:"exclude" toml:"exclude" yaml:"exclude"`
+	RefreshPeriod   string       `json:"refreshPeriod" toml:"refreshPeriod" yaml:"refreshPeriod"`
+}
+
+// An externalFile is an external file in a source directory.
+type externalFile struct {
+	sourceDir string
+	relPath   string
+}
+
+var errUnknownArchiveFormat = errors.New("unknown archive format")
+
+// refreshPeriod returns e's refresh period.
+func (e *External) refreshPeriod() (time.Duration, error) {
+	if e.RefreshPeriod == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(e.RefreshPeriod)
+}
+
+// addExternals adds the externals declared in the external file at relPath in
+// sourceDir to ts.
+func (ts *TargetState) addExternals(fs vfs.FS, sourceDir, relPath string) error {
+	path := filepath.Join(sourceDir, relPath)
+	format := strings.TrimPrefix(filepath.Ext(relPath), ".")
+	decode, ok := formatDecoders[format]
+	if !ok {
+		return fmt.Errorf("%s: unknown format", path)
+	}
+	data, err := ts.executeTemplate(fs, path)
+	if err != nil {
+		return err
+	}
+	externals := make(map[string]*External)
+	if err := decode(data, &externals); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var dns []string
+	if dir := filepath.Dir(relPath); dir != "." {
+		dns, err = ts.dirNames(parseDirNameComponents(splitPathList(dir)))
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedExternalNames(externals) {
+		if err := ts.addExternal(fs, sourceDir, relPath, dns, name, externals[name]); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
+
+// addExternal adds the external e with name relative to the directory dns to
+// ts.
+func (ts *TargetState) addExternal(fs vfs.FS, sourceDir, sourceName string, dns []string, name string, e *External) error {
+	components := append(append([]string{}, dns...), splitPathList(filepath.FromSlash(name))...)
+	targetName := filepath.Join(components...)
+	entries, err := mkdirAll(ts.Entries, "", components[:len(components)-1], sourceName)
+	if err != nil {
+		return err
+	}
+	base := components[len(components)-1]
+	if _, ok := entries[base]; ok {
+		return fmt.Errorf("%s: already in source state", targetName)
+	}
+	refreshPeriod, err := e.refreshPeriod()
+	if err != nil {
+		return err
+	}
+
+	switch e.Type {
+	case ExternalTypeArchive:
+		data, err := ts.readExternal(fs, e.URL, refreshPeriod)
+		if err != nil {
+			return err
+		}
+		dir := newDir(sourceName, targetName, e.Exact, 0o777)
+		if err := dir.addArchive(data, e, sourceName); err != nil {
+			return err
+		}
+		entries[base] = dir
+	case ExternalTypeFile:
+		perm := os.FileMode(0o666)
+		if e.Executable {
+			perm = 0o777
+		}
+		entries[base] = &File{
+			sourceName: sourceName,
+			targetName: targetName,
+			Perm:       perm,
+			evaluateContents: func() ([]byte, error) {
+				return ts.readExternal(fs, e.URL, refreshPeriod)
+			},
+		}
+	case ExternalTypeGitRepo:
+		entries[base] = &GitRepo{
+			sourceName:    sourceName,
+			targetName:    targetName,
+			URL:           e.URL,
+			RefreshPeriod: refreshPeriod,
+			refresh:       ts.RefreshExternals,
+		}
+	default:
+		return fmt.Errorf("%s: unknown type", e.Type)
+	}
+	ts.setEntrySourceDir(targetName, sourceDir)
+	return nil
+}
+
+// readExternal returns the contents of rawURL, using a cached copy if it is
+// younger than refreshPeriod. A zero refreshPeriod means that the cached copy
+// never expires. The cached copy is not written if ts.DryRun is set.
+func (ts *TargetState) readExternal(fs vfs.FS, rawURL string, refreshPeriod time.Duration) ([]byte, error) {
+	cacheFS := ts.CacheFS
+	if cacheFS == nil {
+		cacheFS = fs
+	}
+	var cachePath string
+	if ts.CacheDir != "" {
+		cacheKey := sha256.Sum256([]byte(rawURL))
+		cachePath = filepath.Join(ts.CacheDir, "external", hex.EncodeToString(cacheKey[:]))
+		if info, err := cacheFS.Stat(cachePath); err == nil && !ts.RefreshExternals {
+			if refreshPeriod == 0 || time.Since(info.ModTime()) < refreshPeriod {
+				return cacheFS.ReadFile(cachePath)
+			}
+		}
+	}
+
+	data, err := fetchURL(fs, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" && !ts.DryRun {
+		if err := vfs.MkdirAll(cacheFS, filepath.Dir(cachePath), 0o700); err != nil {
+			return nil, err
+		}
+		if err := cacheFS.WriteFile(cachePath, data, 0o600); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// addArchive adds the contents of the archive data to d.
+func (d *Dir) addArchive(data []byte, e *External, sourceName string) error {
+	format := e.Format
+	if format == "" {
+		format = archiveFormat(e.URL)
+	}
+	ps := NewPatternSet()
+	if len(e.Include) == 0 {
+		if err := ps.Add("**", true); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range e.Include {
+		if err := ps.Add(pattern, true); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range e.Exclude {
+		if err := ps.Add(pattern, false); err != nil {
+			return err
+		}
+	}
+	return walkArchive(data, format, func(name string, mode os.FileMode, contents []byte, linkname string) error {
+		name = path.Clean(name)
+		if name == "." {
+			return nil
+		}
+		components := strings.Split(name, "/")
+		if !validArchiveMemberComponents(components) {
+			return fmt.Errorf("%s: invalid archive member name", name)
+		}
+		if len(components) <= e.StripComponents {
+			return nil
+		}
+		components = components[e.StripComponents:]
+		if !validArchiveMemberComponents(components) {
+			return fmt.Errorf("%s: invalid archive member name", name)
+		}
+		if !ps.Match(strings.Join(components, "/")) {
+			return nil
+		}
+		entries, err := mkdirAll(d.Entries, d.targetName, components[:len(components)-1], sourceName)
+		if err != nil {
+			return err
+		}
+		base := components[len(components)-1]
+		targetName := filepath.Join(d.targetName, filepath.Join(components...))
+		switch {
+		case mode.IsDir():
+			if dir, ok := entries[base].(*Dir); ok {
+				dir.Perm = mode.Perm()
+			} else {
+				entries[base] = newDir(sourceName, targetName, false, mode.Perm())
+			}
+		case mode.IsRegular():
+			entries[base] = &File{
+				sourceName: sourceName,
+				targetName: targetName,
+				Empty:      len(contents) == 0,
+				Perm:       mode.Perm(),
+				contents:   contents,
+			}
+		case mode&os.ModeType == os.ModeSymlink:
+			entries[base] = &Symlink{
+				sourceName: sourceName,
+				targetName: targetName,
+				linkname:   linkname,
+			}
+		}
+		return nil
+	})
+}
+
+// validArchiveMemberComponents returns true if components is a relative path
+// that does not leave the directory containing it. Archive members that are
+// absolute or contain .. components would otherwise be written outside the
+// external's target directory.
+func validArchiveMemberComponents(components []string) bool {
+	for _, component := range components {
+		if component == "" || component == "." || component == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// archiveFormat returns the archive format of rawURL, based on its extension.
+func archiveFormat(rawURL string) string {
+	name := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		name = u.Path
+	}
+	name = strings.ToLower(name)
+	for _, format := range []string{"tar", "tar.bz2", "tar.gz", "tbz2", "tgz", "zip"} {
+		if strings.HasSuffix(name, "."+format) {
+			return format
+		}
+	}
+	return ""
+}
+
+// fetchURL returns the contents of rawURL. file URLs are read from fs.
+func fetchURL(fs vfs.FS, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		return fs.ReadFile(filepath.FromSlash(u.Path))
+	case "http", "https":
+		//nolint:gosec,noctx
+		resp, err := http.Get(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: %s", rawURL, resp.Status)
+		}
+		return ioutil.ReadAll(resp.Body)
+	default:
+		return nil, fmt.Errorf("%s: unsupported scheme", rawURL)
+	}
+}
+
+// mkdirAll returns the entries of the directory dirNames below entries,
+// creating any missing directories. parentTargetName is the target name of the
+// directory containing entries.
+func mkdirAll(entries map[string]Entry, parentTargetName string, dirNames []string, sourceName string) (map[string]Entry, error) {
+	for i, dirName := range dirNames {
+		targetName := filepath.Join(parentTargetName, filepath.Join(dirNames[:i+1]...))
+		switch entry := entries[dirName].(type) {
+		case nil:
+			dir := newDir(sourceName, targetName, false, 0o777)
+			entries[dirName] = dir
+			entries = dir.Entries
+		case *Dir:
+			entries = entry.Entries
+		default:
+			return nil, fmt.Errorf("%s: not a directory", targetName)
+		}
+	}
+	return entries, nil
+}
+
+// sortedExternalNames returns the sorted names of externals.
+func sortedExternalNames(externals map[string]*External) []string {
+	names := make([]string, 0, len(externals))
+	for name := range externals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// walkArchive calls f for each member of the archive data in format.
+func walkArchive(data []byte, format string, f func(name string, mode os.FileMode, contents []byte, linkname string) error) error {
+	var r io.Reader = bytes.NewReader(data)
+	switch format {
+	case "tar":
+	case "tar.bz2", "tbz2":
+		r = bzip2.NewReader(r)
+	case "tar.gz", "tgz":
+		gzipReader, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	case "zip":
+		return walkZIP(data, f)
+	default:
+		return fmt.Errorf("%s: %w", format, errUnknownArchiveFormat)
+	}
+
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = f(header.Name, os.ModeDir|os.FileMode(header.Mode).Perm(), nil, "")
+		case tar.TypeReg:
+			var contents []byte
+			contents, err = ioutil.ReadAll(tarReader)
+			if err == nil {
+				err = f(header.Name, os.FileMode(header.Mode).Perm(), contents, "")
+			}
+		case tar.TypeSymlink:
+			err = f(header.Name, os.ModeSymlink, nil, header.Linkname)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// walkZIP calls f for each member of the zip archive data.
+func walkZIP(data []byte, f func(name string, mode os.FileMode, contents []byte, linkname string) error) error {
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, zipFile := range zipReader.File {
+		mode := zipFile.Mode()
+		var contents []byte
+		if mode.IsRegular() || mode&os.ModeType == os.ModeSymlink {
+			rc, err := zipFile.Open()
+			if err != nil {
+				return err
+			}
+			contents, err = ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		var linkname string
+		if mode&os.ModeType == os.ModeSymlink {
+			linkname, contents = string(contents), nil
+		}
+		if err := f(zipFile.Name, mode, contents, linkname); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package chezmoi
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestExternal(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/archives": map[string]interface{}{
+			"archive.tar.gz": newTestTARGZ(t),
+			"archive.zip":    newTestZIP(t),
+			"file":           "# contents of file\n",
+		},
+		"/home/user": &vfst.Dir{Perm: 0o755},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			".chezmoiexternal.toml": `` +
+				"[\".file\"]\n" +
+				"    type = \"file\"\n" +
+				"    url = \"file:///archives/file\"\n" +
+				"    executable = {{ .executable }}\n" +
+				"[\".tar\"]\n" +
+				"    type = \"archive\"\n" +
+				"    url = \"file:///archives/archive.tar.gz\"\n" +
+				"    stripComponents = 1\n" +
+				"    exclude = [\"dir/exclude\"]\n",
+			"dot_dir/.chezmoiexternal.json": `` +
+				`{"zip/sub": {"type": "archive", "url": "file:///archives/archive.zip", "include": ["foo"]}}`,
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithCacheDir("/home/user/.cache/chezmoi"),
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+		WithTemplateData(map[string]interface{}{
+			"executable": true,
+		}),
+		WithUmask(0o22),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	applyOptions := &ApplyOptions{
+		DestDir: ts.DestDir,
+		Ignore:  ts.TargetIgnore.Match,
+		Stdout:  os.Stdout,
+		Umask:   0o22,
+	}
+	require.NoError(t, ts.Apply(fs, NewFSMutator(fs), false, applyOptions))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.file",
+			vfst.TestModeIsRegular,
+			vfst.TestModePerm(0o755),
+			vfst.TestContentsString("# contents of file\n"),
+		),
+		vfst.TestPath("/home/user/.tar/dir",
+			vfst.TestIsDir,
+		),
+		vfst.TestPath("/home/user/.tar/dir/file",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of dir/file\n"),
+		),
+		vfst.TestPath("/home/user/.tar/dir/exclude",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.tar/symlink",
+			vfst.TestModeType(os.ModeSymlink),
+			vfst.TestSymlinkTarget("dir/file"),
+		),
+		vfst.TestPath("/home/user/.dir/zip/sub/foo",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of foo\n"),
+		),
+		vfst.TestPath("/home/user/.dir/zip/sub/bar",
+			vfst.TestDoesNotExist,
+		),
+	)
+
+	// Check that the cached copy is used.
+	require.NoError(t, fs.WriteFile("/archives/file", []byte("# new contents of file\n"), 0o666))
+	data, err := ts.readExternal(fs, "file:///archives/file", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("# contents of file\n"), data)
+
+	// Check that the cached copy is not used when refreshing externals.
+	ts.RefreshExternals = true
+	data, err = ts.readExternal(fs, "file:///archives/file", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("# new contents of file\n"), data)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.cache/chezmoi/external",
+			vfst.TestIsDir,
+			vfst.TestModePerm(0o700),
+		),
+	)
+
+	// Check that the cached copy is not updated in dry run mode.
+	ts.DryRun = true
+	require.NoError(t, fs.WriteFile("/archives/file", []byte("# newer contents of file\n"), 0o666))
+	data, err = ts.readExternal(fs, "file:///archives/file", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("# newer contents of file\n"), data)
+	ts.DryRun = false
+	ts.RefreshExternals = false
+	data, err = ts.readExternal(fs, "file:///archives/file", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("# new contents of file\n"), data)
+}
+
+func TestExternalInvalidArchiveMember(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		memberName      string
+		stripComponents int
+	}{
+		{
+			name:       "absolute",
+			memberName: "/home/user/.bashrc",
+		},
+		{
+			name:       "dot_dot",
+			memberName: "../.bashrc",
+		},
+		{
+			name:       "dot_dot_after_clean",
+			memberName: "archive/../../.bashrc",
+		},
+		{
+			name:            "dot_dot_after_strip_components",
+			memberName:      "archive/../../../.bashrc",
+			stripComponents: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/archives/archive.tar.gz": newTestTARGZWithHeaders(t, []*tar.Header{
+					{Typeflag: tar.TypeReg, Name: tc.memberName, Mode: 0o644, Size: int64(len("# contents of dir/file\n"))},
+				}),
+				"/home/user/.local/share/chezmoi/.chezmoiexternal.toml": `` +
+					"[\".dir\"]\n" +
+					"    type = \"archive\"\n" +
+					"    url = \"file:///archives/archive.tar.gz\"\n" +
+					"    stripComponents = " + strconv.Itoa(tc.stripComponents) + "\n",
+			})
+			require.NoError(t, err)
+			defer cleanup()
+
+			ts := NewTargetState(
+				WithDestDir("/home/user"),
+				WithSourceDir("/home/user/.local/share/chezmoi"),
+			)
+			err = ts.Populate(fs, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid archive member name")
+		})
+	}
+}
+
+func newTestTARGZ(t *testing.T) []byte {
+	t.Helper()
+	return newTestTARGZWithHeaders(t, []*tar.Header{
+		{Typeflag: tar.TypeDir, Name: "archive/", Mode: 0o755},
+		{Typeflag: tar.TypeDir, Name: "archive/dir/", Mode: 0o755},
+		{Typeflag: tar.TypeReg, Name: "archive/dir/exclude", Mode: 0o644},
+		{Typeflag: tar.TypeReg, Name: "archive/dir/file", Mode: 0o644, Size: int64(len("# contents of dir/file\n"))},
+		{Typeflag: tar.TypeSymlink, Name: "archive/symlink", Linkname: "dir/file"},
+	})
+}
+
+func newTestTARGZWithHeaders(t *testing.T, headers []*tar.Header) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(b)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, header := range headers {
+		require.NoError(t, tarWriter.WriteHeader(header))
+		if header.Size != 0 {
+			_, err := tarWriter.Write([]byte("# contents of dir/file\n"))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+	return b.Bytes()
+}
+
+func newTestZIP(t *testing.T) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(b)
+	for _, name := range []string{"foo", "bar"} {
+		w, err := zipWriter.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("# contents of " + name + "\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	return b.Bytes()
+}
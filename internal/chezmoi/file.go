@@ -13,21 +13,29 @@ import (
 
 // A FileAttributes holds attributes parsed from a source file name.
 type FileAttributes struct {
-	Name      string
-	Mode      os.FileMode
-	Empty     bool
-	Encrypted bool
-	Template  bool
+	Name         string
+	Mode         os.FileMode
+	Create       bool
+	Empty        bool
+	Encrypted    bool
+	Modify       bool
+	NameTemplate bool
+	Template     bool
 }
 
 // A File represents the target state of a file.
 type File struct {
 	sourceName       string
+	sourcePath       string
 	targetName       string
+	Create           bool
 	Empty            bool
 	Encrypted        bool
+	Modify           bool
 	Perm             os.FileMode
 	Template         bool
+	Owner            string
+	Group            string
 	contents         []byte
 	contentsErr      error
 	evaluateContents func() ([]byte, error)
@@ -37,10 +45,15 @@ type fileConcreteValue struct {
 	Type       string `json:"type" yaml:"type"`
 	SourcePath string `json:"sourcePath" yaml:"sourcePath"`
 	TargetPath string `json:"targetPath" yaml:"targetPath"`
+	Create     bool   `json:"create" yaml:"create"`
 	Empty      bool   `json:"empty" yaml:"empty"`
 	Encrypted  bool   `json:"encrypted" yaml:"encrypted"`
+	Modify     bool   `json:"modify" yaml:"modify"`
 	Perm       int    `json:"perm" yaml:"perm"`
+	ReadOnly   bool   `json:"readonly" yaml:"readonly"`
 	Template   bool   `json:"template" yaml:"template"`
+	Owner      string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Group      string `json:"group,omitempty" yaml:"group,omitempty"`
 	Contents   string `json:"contents" yaml:"contents"`
 }
 
@@ -48,14 +61,26 @@ type fileConcreteValue struct {
 func ParseFileAttributes(sourceName string) FileAttributes {
 	name := sourceName
 	mode := os.FileMode(0o666)
+	create := false
 	empty := false
 	encrypted := false
+	modify := false
+	nameTemplate := false
 	template := false
 	if strings.HasPrefix(name, symlinkPrefix) {
 		name = strings.TrimPrefix(name, symlinkPrefix)
 		mode |= os.ModeSymlink
 	} else {
 		private := false
+		readOnly := false
+		switch {
+		case strings.HasPrefix(name, createPrefix):
+			name = strings.TrimPrefix(name, createPrefix)
+			create = true
+		case strings.HasPrefix(name, modifyPrefix):
+			name = strings.TrimPrefix(name, modifyPrefix)
+			modify = true
+		}
 		if strings.HasPrefix(name, encryptedPrefix) {
 			name = strings.TrimPrefix(name, encryptedPrefix)
 			encrypted = true
@@ -64,6 +89,10 @@ func ParseFileAttributes(sourceName string) FileAttributes {
 			name = strings.TrimPrefix(name, privatePrefix)
 			private = true
 		}
+		if strings.HasPrefix(name, readOnlyPrefix) {
+			name = strings.TrimPrefix(name, readOnlyPrefix)
+			readOnly = true
+		}
 		if strings.HasPrefix(name, emptyPrefix) {
 			name = strings.TrimPrefix(name, emptyPrefix)
 			empty = true
@@ -75,20 +104,24 @@ func ParseFileAttributes(sourceName string) FileAttributes {
 		if private {
 			mode &= 0o700
 		}
+		if readOnly {
+			mode &^= 0o222
+		}
 	}
-	if strings.HasPrefix(name, dotPrefix) {
-		name = "." + strings.TrimPrefix(name, dotPrefix)
-	}
-	if strings.HasSuffix(name, TemplateSuffix) {
-		name = strings.TrimSuffix(name, TemplateSuffix)
-		template = true
+	if strings.HasPrefix(name, nameTmplPrefix) {
+		name = strings.TrimPrefix(name, nameTmplPrefix)
+		nameTemplate = true
 	}
+	name, template = parseNameSuffix(parseNamePrefix(name))
 	return FileAttributes{
-		Name:      name,
-		Mode:      mode,
-		Empty:     empty,
-		Encrypted: encrypted,
-		Template:  template,
+		Name:         name,
+		Mode:         mode,
+		Create:       create,
+		Empty:        empty,
+		Encrypted:    encrypted,
+		Modify:       modify,
+		NameTemplate: nameTemplate,
+		Template:     template,
 	}
 }
 
@@ -98,12 +131,21 @@ func (fa FileAttributes) SourceName() string {
 	//nolint:exhaustive
 	switch fa.Mode & os.ModeType {
 	case 0:
+		switch {
+		case fa.Create:
+			sourceName += createPrefix
+		case fa.Modify:
+			sourceName += modifyPrefix
+		}
 		if fa.Encrypted {
 			sourceName += encryptedPrefix
 		}
 		if fa.Mode.Perm()&os.FileMode(0o77) == os.FileMode(0) {
 			sourceName += privatePrefix
 		}
+		if fa.Mode.Perm()&os.FileMode(0o222) == os.FileMode(0) {
+			sourceName += readOnlyPrefix
+		}
 		if fa.Empty {
 			sourceName += emptyPrefix
 		}
@@ -115,15 +157,10 @@ func (fa FileAttributes) SourceName() string {
 	default:
 		panic(fmt.Sprintf("%+v: unsupported type", fa))
 	}
-	if strings.HasPrefix(fa.Name, ".") {
-		sourceName += dotPrefix + strings.TrimPrefix(fa.Name, ".")
-	} else {
-		sourceName += fa.Name
-	}
-	if fa.Template {
-		sourceName += TemplateSuffix
+	if fa.NameTemplate {
+		sourceName += nameTmplPrefix
 	}
-	return sourceName
+	return formatNameSuffix(sourceName+formatNamePrefix(fa.Name), fa.Name, fa.Template)
 }
 
 // AppendAllEntries appends all f to allEntries.
@@ -140,7 +177,10 @@ func (f *File) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Appl
 	if err != nil {
 		return err
 	}
-	targetPath := filepath.Join(applyOptions.DestDir, f.targetName)
+	targetPath := applyOptions.TargetPath(f.targetName)
+	if applyOptions.Mode == ModeSymlink && f.symlinkable(contents) {
+		return f.applySymlink(fs, mutator, targetPath)
+	}
 	var info os.FileInfo
 	if follow {
 		info, err = fs.Stat(targetPath)
@@ -149,6 +189,9 @@ func (f *File) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Appl
 	}
 	var currData []byte
 	switch {
+	case err == nil && f.Create:
+		// Never overwrite an existing target.
+		return nil
 	case err == nil && info.Mode().IsRegular():
 		if isEmpty(contents) && !f.Empty {
 			return mutator.RemoveAll(targetPath)
@@ -158,6 +201,13 @@ func (f *File) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Appl
 			return err
 		}
 		if !bytes.Equal(currData, contents) {
+			// Make read-only targets writable so that they can be
+			// replaced.
+			if perm := info.Mode().Perm(); perm&0o200 == 0 {
+				if err := mutator.Chmod(targetPath, perm|0o200); err != nil {
+					return err
+				}
+			}
 			break
 		}
 		if info.Mode().Perm() != f.Perm&^applyOptions.Umask {
@@ -165,7 +215,7 @@ func (f *File) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Appl
 				return err
 			}
 		}
-		return nil
+		return applyOwnership(fs, mutator, targetPath, f.Owner, f.Group)
 	case err == nil:
 		if err := mutator.RemoveAll(targetPath); err != nil {
 			return err
@@ -177,11 +227,14 @@ func (f *File) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Appl
 	if isEmpty(contents) && !f.Empty {
 		return nil
 	}
-	return mutator.WriteFile(targetPath, contents, f.Perm&^applyOptions.Umask, currData)
+	if err := mutator.WriteFile(targetPath, contents, f.Perm&^applyOptions.Umask, currData); err != nil {
+		return err
+	}
+	return applyOwnership(fs, mutator, targetPath, f.Owner, f.Group)
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (f *File) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
+func (f *File) ConcreteValue(ignore func(string) bool, sourceDir func(Entry) string, umask os.FileMode, recursive bool) (interface{}, error) {
 	if ignore(f.targetName) {
 		return nil, nil
 	}
@@ -191,12 +244,17 @@ func (f *File) ConcreteValue(ignore func(string) bool, sourceDir string, umask o
 	}
 	return &fileConcreteValue{
 		Type:       "file",
-		SourcePath: filepath.Join(sourceDir, f.SourceName()),
+		SourcePath: filepath.Join(sourceDir(f), f.SourceName()),
 		TargetPath: f.TargetName(),
+		Create:     f.Create,
 		Empty:      f.Empty,
 		Encrypted:  f.Encrypted,
+		Modify:     f.Modify,
 		Perm:       int(f.Perm &^ umask),
+		ReadOnly:   f.ReadOnly(),
 		Template:   f.Template,
+		Owner:      f.Owner,
+		Group:      f.Group,
 		Contents:   string(contents),
 	}, nil
 }
@@ -229,16 +287,82 @@ func (f *File) Private() bool {
 	return f.Perm&0o77 == 0
 }
 
+// ReadOnly returns true if f is read-only.
+func (f *File) ReadOnly() bool {
+	return f.Perm&0o222 == 0
+}
+
+// symlinkable returns true if f, with contents, can be applied as a symlink to
+// its source file. Only files whose source file is identical to the target are
+// symlinkable.
+func (f *File) symlinkable(contents []byte) bool {
+	switch {
+	case f.sourcePath == "":
+		return false
+	case f.Create || f.Encrypted || f.Modify || f.Template:
+		return false
+	case f.Executable() || f.Private() || f.ReadOnly():
+		return false
+	case f.Owner != "" || f.Group != "":
+		return false
+	case isEmpty(contents) && !f.Empty:
+		return false
+	default:
+		return true
+	}
+}
+
 // SourceName implements Entry.SourceName.
 func (f *File) SourceName() string {
 	return f.sourceName
 }
 
+// SourcePath returns the path of f's source file, or the empty string if f
+// does not have its own source file, for example if f is from an external.
+func (f *File) SourcePath() string {
+	return f.sourcePath
+}
+
 // TargetName implements Entry.TargetName.
 func (f *File) TargetName() string {
 	return f.targetName
 }
 
+// applySymlink ensures that targetPath in fs is a symlink to f's source file.
+func (f *File) applySymlink(fs vfs.FS, mutator Mutator, targetPath string) error {
+	linked, err := f.linkedFrom(fs, targetPath)
+	if err != nil {
+		return err
+	}
+	if linked {
+		return nil
+	}
+	return mutator.WriteSymlink(f.sourcePath, targetPath)
+}
+
+// linkedFrom returns true if targetPath in fs is a symlink to f's source file.
+func (f *File) linkedFrom(fs vfs.FS, targetPath string) (bool, error) {
+	if f.sourcePath == "" {
+		return false, nil
+	}
+	switch info, err := fs.Lstat(targetPath); {
+	case err == nil && info.Mode()&os.ModeType == os.ModeSymlink:
+	case err == nil || os.IsNotExist(err):
+		return false, nil
+	default:
+		return false, err
+	}
+	currentTarget, err := fs.Readlink(targetPath)
+	if err != nil {
+		return false, err
+	}
+	rawSourcePath, err := fs.RawPath(f.sourcePath)
+	if err != nil {
+		return false, err
+	}
+	return currentTarget == rawSourcePath, nil
+}
+
 // archive writes f to w.
 func (f *File) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *tar.Header, umask os.FileMode) error {
 	if ignore(f.targetName) {
@@ -256,6 +380,9 @@ func (f *File) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *
 	header.Name = f.targetName
 	header.Size = int64(len(contents))
 	header.Mode = int64(f.Perm &^ umask)
+	if err := setTarHeaderOwnership(&header, f.Owner, f.Group); err != nil {
+		return err
+	}
 	if err := w.WriteHeader(&header); err != nil {
 		return nil
 	}
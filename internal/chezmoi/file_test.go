@@ -114,6 +114,114 @@ func TestFileAttributes(t *testing.T) {
 				Encrypted: true,
 			},
 		},
+		{
+			sourceName: "create_dot_foo",
+			fa: FileAttributes{
+				Name:   ".foo",
+				Mode:   0o666,
+				Create: true,
+			},
+		},
+		{
+			sourceName: "create_private_executable_foo.tmpl",
+			fa: FileAttributes{
+				Name:     "foo",
+				Mode:     0o700,
+				Create:   true,
+				Template: true,
+			},
+		},
+		{
+			sourceName: "modify_dot_foo",
+			fa: FileAttributes{
+				Name:   ".foo",
+				Mode:   0o666,
+				Modify: true,
+			},
+		},
+		{
+			sourceName: "modify_private_executable_foo.tmpl",
+			fa: FileAttributes{
+				Name:     "foo",
+				Mode:     0o700,
+				Modify:   true,
+				Template: true,
+			},
+		},
+		{
+			sourceName: "readonly_dot_foo",
+			fa: FileAttributes{
+				Name: ".foo",
+				Mode: 0o444,
+			},
+		},
+		{
+			sourceName: "private_readonly_executable_foo",
+			fa: FileAttributes{
+				Name: "foo",
+				Mode: 0o500,
+			},
+		},
+		{
+			sourceName: "private_nametmpl_dot_{{ .host }}.conf.tmpl",
+			fa: FileAttributes{
+				Name:         ".{{ .host }}.conf",
+				Mode:         0o600,
+				NameTemplate: true,
+				Template:     true,
+			},
+		},
+		{
+			sourceName: "literal_nametmpl_foo",
+			fa: FileAttributes{
+				Name: "nametmpl_foo",
+				Mode: 0o666,
+			},
+		},
+		{
+			sourceName: "literal_dot_foo",
+			fa: FileAttributes{
+				Name: "dot_foo",
+				Mode: 0o666,
+			},
+		},
+		{
+			sourceName: "private_literal_executable_foo",
+			fa: FileAttributes{
+				Name: "executable_foo",
+				Mode: 0o600,
+			},
+		},
+		{
+			sourceName: "literal_literal_foo",
+			fa: FileAttributes{
+				Name: "literal_foo",
+				Mode: 0o666,
+			},
+		},
+		{
+			sourceName: "foo.tmpl.literal",
+			fa: FileAttributes{
+				Name: "foo.tmpl",
+				Mode: 0o666,
+			},
+		},
+		{
+			sourceName: "foo.literal.literal.tmpl",
+			fa: FileAttributes{
+				Name:     "foo.literal",
+				Mode:     0o666,
+				Template: true,
+			},
+		},
+		{
+			sourceName: "symlink_literal_run_foo.tmpl.literal.tmpl",
+			fa: FileAttributes{
+				Name:     "run_foo.tmpl",
+				Mode:     os.ModeSymlink | 0o666,
+				Template: true,
+			},
+		},
 	} {
 		t.Run(tc.sourceName, func(t *testing.T) {
 			assert.Equal(t, tc.fa, ParseFileAttributes(tc.sourceName))
@@ -59,6 +59,12 @@ func (m *GitDiffMutator) Chmod(name string, mode os.FileMode) error {
 	})
 }
 
+// Chown implements Mutator.Chown.
+func (m *GitDiffMutator) Chown(name string, uid, gid int) error {
+	// git diffs cannot represent changes in ownership.
+	return nil
+}
+
 // IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
 func (m *GitDiffMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
 	return m.m.IdempotentCmdOutput(cmd)
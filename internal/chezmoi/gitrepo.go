@@ -0,0 +1,106 @@
+package chezmoi
+
+import (
+	"archive/tar"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// A GitRepo represents the target state of a git repository cloned from an
+// external URL.
+type GitRepo struct {
+	sourceName    string
+	targetName    string
+	URL           string
+	RefreshPeriod time.Duration
+	refresh       bool
+}
+
+type gitRepoConcreteValue struct {
+	Type          string `json:"type" yaml:"type"`
+	SourcePath    string `json:"sourcePath" yaml:"sourcePath"`
+	TargetPath    string `json:"targetPath" yaml:"targetPath"`
+	URL           string `json:"url" yaml:"url"`
+	RefreshPeriod string `json:"refreshPeriod" yaml:"refreshPeriod"`
+}
+
+// AppendAllEntries appends g to allEntries.
+func (g *GitRepo) AppendAllEntries(allEntries []Entry) []Entry {
+	return append(allEntries, g)
+}
+
+// Apply clones g's repository if it does not already exist, and pulls it if
+// it is due to be refreshed.
+func (g *GitRepo) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error {
+	if applyOptions.Ignore(g.targetName) {
+		return nil
+	}
+	targetPath := applyOptions.TargetPath(g.targetName)
+	rawTargetPath, err := fs.RawPath(targetPath)
+	if err != nil {
+		return err
+	}
+	switch _, err := fs.Stat(filepath.Join(targetPath, ".git")); {
+	case os.IsNotExist(err):
+		return mutator.RunCmd(exec.Command("git", "clone", g.URL, rawTargetPath))
+	case err != nil:
+		return err
+	}
+
+	if !g.refresh {
+		if g.RefreshPeriod == 0 {
+			return nil
+		}
+		// git updates FETCH_HEAD on every pull.
+		info, err := fs.Stat(filepath.Join(targetPath, ".git", "FETCH_HEAD"))
+		if err == nil && time.Since(info.ModTime()) < g.RefreshPeriod {
+			return nil
+		}
+	}
+	cmd := exec.Command("git", "pull", "--ff-only")
+	cmd.Dir = rawTargetPath
+	return mutator.RunCmd(cmd)
+}
+
+// ConcreteValue implements Entry.ConcreteValue.
+func (g *GitRepo) ConcreteValue(ignore func(string) bool, sourceDir func(Entry) string, umask os.FileMode, recursive bool) (interface{}, error) {
+	if ignore(g.targetName) {
+		return nil, nil
+	}
+	var refreshPeriod string
+	if g.RefreshPeriod != 0 {
+		refreshPeriod = g.RefreshPeriod.String()
+	}
+	return &gitRepoConcreteValue{
+		Type:          "git-repo",
+		SourcePath:    filepath.Join(sourceDir(g), g.SourceName()),
+		TargetPath:    g.TargetName(),
+		URL:           g.URL,
+		RefreshPeriod: refreshPeriod,
+	}, nil
+}
+
+// Evaluate evaluates g.
+func (g *GitRepo) Evaluate(ignore func(string) bool) error {
+	return nil
+}
+
+// SourceName implements Entry.SourceName.
+func (g *GitRepo) SourceName() string {
+	return g.sourceName
+}
+
+// TargetName implements Entry.TargetName.
+func (g *GitRepo) TargetName() string {
+	return g.targetName
+}
+
+// archive does nothing, as the contents of git repositories are not known
+// until they are cloned.
+func (g *GitRepo) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *tar.Header, umask os.FileMode) error {
+	return nil
+}
@@ -74,7 +74,7 @@ func (g *GPG) Encrypt(filename string, plaintext []byte) ([]byte, error) {
 		}
 		args = append(args, "--encrypt")
 	}
-	args = append(args, filename)
+	args = append(args, inputFilename)
 
 	//nolint:gosec
 	cmd := exec.Command(g.Command, args...)
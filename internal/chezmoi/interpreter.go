@@ -0,0 +1,37 @@
+package chezmoi
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// An Interpreter interprets scripts.
+type Interpreter struct {
+	Command string
+	Args    []string
+}
+
+// ExecCommand returns the *exec.Cmd to interpret name. If i is nil or has no
+// command then name is executed directly.
+func (i *Interpreter) ExecCommand(name string) *exec.Cmd {
+	if i == nil || i.Command == "" {
+		//nolint:gosec
+		return exec.Command(name)
+	}
+	args := make([]string, 0, len(i.Args)+1)
+	args = append(args, i.Args...)
+	args = append(args, name)
+	//nolint:gosec
+	return exec.Command(i.Command, args...)
+}
+
+// findInterpreter returns the interpreter for name from interpreters, keyed by
+// file extension without the leading dot, or nil if there is none.
+func findInterpreter(interpreters map[string]*Interpreter, name string) *Interpreter {
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	if ext == "" {
+		return nil
+	}
+	return interpreters[strings.ToLower(ext)]
+}
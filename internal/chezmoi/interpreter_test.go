@@ -0,0 +1,48 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpreter(t *testing.T) {
+	interpreters := map[string]*Interpreter{
+		"py": {
+			Command: "python3",
+		},
+		"ps1": {
+			Command: "powershell",
+			Args:    []string{"-NoLogo", "-File"},
+		},
+	}
+	for _, tc := range []struct {
+		name         string
+		expectedArgs []string
+	}{
+		{
+			name:         "script",
+			expectedArgs: []string{"script"},
+		},
+		{
+			name:         "script.sh",
+			expectedArgs: []string{"script.sh"},
+		},
+		{
+			name:         "script.py",
+			expectedArgs: []string{"python3", "script.py"},
+		},
+		{
+			name:         "script.PY",
+			expectedArgs: []string{"python3", "script.PY"},
+		},
+		{
+			name:         "script.ps1",
+			expectedArgs: []string{"powershell", "-NoLogo", "-File", "script.ps1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedArgs, findInterpreter(interpreters, tc.name).ExecCommand(tc.name).Args)
+		})
+	}
+}
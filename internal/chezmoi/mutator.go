@@ -8,6 +8,7 @@ import (
 // A Mutator makes changes.
 type Mutator interface {
 	Chmod(name string, mode os.FileMode) error
+	Chown(name string, uid, gid int) error
 	IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error)
 	Mkdir(name string, perm os.FileMode) error
 	RemoveAll(name string) error
@@ -0,0 +1,113 @@
+package chezmoi
+
+import (
+	"archive/tar"
+	"fmt"
+	"os/user"
+	"strconv"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// An ownership is a resolved owner and group. An id of -1 means that it is
+// not set.
+type ownership struct {
+	uid   int
+	gid   int
+	uname string
+	gname string
+}
+
+// lookupOwnership resolves owner and group, which can be either names or
+// numeric ids. Numeric ids do not need to exist.
+func lookupOwnership(owner, group string) (*ownership, error) {
+	o := &ownership{
+		uid: -1,
+		gid: -1,
+	}
+	if owner != "" {
+		var u *user.User
+		var err error
+		if uid, atoiErr := strconv.Atoi(owner); atoiErr == nil {
+			o.uid = uid
+			u, err = user.LookupId(owner)
+		} else {
+			u, err = user.Lookup(owner)
+			if err != nil {
+				return nil, err
+			}
+			if o.uid, err = strconv.Atoi(u.Uid); err != nil {
+				return nil, fmt.Errorf("%s: invalid user id %s", owner, u.Uid)
+			}
+		}
+		if err == nil {
+			o.uname = u.Username
+		}
+	}
+	if group != "" {
+		var g *user.Group
+		var err error
+		if gid, atoiErr := strconv.Atoi(group); atoiErr == nil {
+			o.gid = gid
+			g, err = user.LookupGroupId(group)
+		} else {
+			g, err = user.LookupGroup(group)
+			if err != nil {
+				return nil, err
+			}
+			if o.gid, err = strconv.Atoi(g.Gid); err != nil {
+				return nil, fmt.Errorf("%s: invalid group id %s", group, g.Gid)
+			}
+		}
+		if err == nil {
+			o.gname = g.Name
+		}
+	}
+	return o, nil
+}
+
+// applyOwnership ensures that targetPath in fs is owned by owner and group.
+func applyOwnership(fs vfs.FS, mutator Mutator, targetPath, owner, group string) error {
+	if owner == "" && group == "" {
+		return nil
+	}
+	o, err := lookupOwnership(owner, group)
+	if err != nil {
+		return err
+	}
+	uid, gid := o.uid, o.gid
+	if info, err := fs.Lstat(targetPath); err == nil {
+		if currUID, currGID, ok := fileOwnership(info); ok {
+			if uid == currUID {
+				uid = -1
+			}
+			if gid == currGID {
+				gid = -1
+			}
+		}
+	}
+	if uid == -1 && gid == -1 {
+		return nil
+	}
+	return mutator.Chown(targetPath, uid, gid)
+}
+
+// setTarHeaderOwnership sets the ownership of header to owner and group.
+func setTarHeaderOwnership(header *tar.Header, owner, group string) error {
+	if owner == "" && group == "" {
+		return nil
+	}
+	o, err := lookupOwnership(owner, group)
+	if err != nil {
+		return err
+	}
+	if o.uid != -1 {
+		header.Uid = o.uid
+		header.Uname = o.uname
+	}
+	if o.gid != -1 {
+		header.Gid = o.gid
+		header.Gname = o.gname
+	}
+	return nil
+}
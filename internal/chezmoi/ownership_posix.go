@@ -0,0 +1,17 @@
+// +build !windows
+
+package chezmoi
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwnership returns the uid and gid of info.
+func fileOwnership(info os.FileInfo) (int, int, bool) {
+	statT, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(statT.Uid), int(statT.Gid), true
+}
@@ -0,0 +1,10 @@
+// +build windows
+
+package chezmoi
+
+import "os"
+
+// fileOwnership always returns false on Windows.
+func fileOwnership(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,167 @@
+package chezmoi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// A PrivilegedMutator wraps a Mutator and makes changes to paths in privileged
+// directories by running commands with a helper, like sudo or doas. All other
+// changes are passed to the wrapped Mutator.
+type PrivilegedMutator struct {
+	m       Mutator
+	fs      vfs.FS
+	command string
+	args    []string
+	dirs    []string
+}
+
+// NewPrivilegedMutator returns a new PrivilegedMutator that changes paths in
+// dirs in fs by running command with args.
+func NewPrivilegedMutator(m Mutator, fs vfs.FS, command string, args, dirs []string) *PrivilegedMutator {
+	return &PrivilegedMutator{
+		m:       m,
+		fs:      fs,
+		command: command,
+		args:    args,
+		dirs:    dirs,
+	}
+}
+
+// Chmod implements Mutator.Chmod.
+func (m *PrivilegedMutator) Chmod(name string, mode os.FileMode) error {
+	if !m.isPrivileged(name) {
+		return m.m.Chmod(name, mode)
+	}
+	return m.run("chmod", formatMode(mode), m.rawPath(name))
+}
+
+// Chown implements Mutator.Chown.
+func (m *PrivilegedMutator) Chown(name string, uid, gid int) error {
+	if !m.isPrivileged(name) {
+		return m.m.Chown(name, uid, gid)
+	}
+	var owner string
+	if uid != -1 {
+		owner = strconv.Itoa(uid)
+	}
+	if gid != -1 {
+		owner += ":" + strconv.Itoa(gid)
+	}
+	return m.run("chown", owner, m.rawPath(name))
+}
+
+// IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
+func (m *PrivilegedMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	return m.m.IdempotentCmdOutput(cmd)
+}
+
+// Mkdir implements Mutator.Mkdir.
+func (m *PrivilegedMutator) Mkdir(name string, perm os.FileMode) error {
+	if !m.isPrivileged(name) {
+		return m.m.Mkdir(name, perm)
+	}
+	return m.run("mkdir", "-m", formatMode(perm), m.rawPath(name))
+}
+
+// RemoveAll implements Mutator.RemoveAll.
+func (m *PrivilegedMutator) RemoveAll(name string) error {
+	if !m.isPrivileged(name) {
+		return m.m.RemoveAll(name)
+	}
+	return m.run("rm", "-rf", m.rawPath(name))
+}
+
+// Rename implements Mutator.Rename.
+func (m *PrivilegedMutator) Rename(oldpath, newpath string) error {
+	if !m.isPrivileged(oldpath) && !m.isPrivileged(newpath) {
+		return m.m.Rename(oldpath, newpath)
+	}
+	return m.run("mv", "-f", m.rawPath(oldpath), m.rawPath(newpath))
+}
+
+// RunCmd implements Mutator.RunCmd.
+func (m *PrivilegedMutator) RunCmd(cmd *exec.Cmd) error {
+	return m.m.RunCmd(cmd)
+}
+
+// Stat implements Mutator.Stat.
+func (m *PrivilegedMutator) Stat(name string) (os.FileInfo, error) {
+	return m.m.Stat(name)
+}
+
+// WriteFile implements Mutator.WriteFile.
+func (m *PrivilegedMutator) WriteFile(name string, data []byte, perm os.FileMode, currData []byte) error {
+	if !m.isPrivileged(name) {
+		return m.m.WriteFile(name, data, perm, currData)
+	}
+	// Write the contents to a temporary file as the current user and then
+	// install it with the helper.
+	f, err := ioutil.TempFile("", "chezmoi-"+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(f.Name())
+	}()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return m.run("install", "-m", formatMode(perm), f.Name(), m.rawPath(name))
+}
+
+// WriteSymlink implements Mutator.WriteSymlink.
+func (m *PrivilegedMutator) WriteSymlink(oldname, newname string) error {
+	if !m.isPrivileged(newname) {
+		return m.m.WriteSymlink(oldname, newname)
+	}
+	return m.run("ln", "-sfn", oldname, m.rawPath(newname))
+}
+
+// isPrivileged returns true if name is in one of m's privileged directories.
+func (m *PrivilegedMutator) isPrivileged(name string) bool {
+	for _, dir := range m.dirs {
+		if name == dir || strings.HasPrefix(name, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// rawPath returns the raw path of name in m's filesystem, or name if it cannot
+// be determined.
+func (m *PrivilegedMutator) rawPath(name string) string {
+	if rawPath, err := m.fs.RawPath(name); err == nil {
+		return rawPath
+	}
+	return name
+}
+
+// run runs the helper with args. The helper is connected to the terminal so
+// that it can prompt for a password.
+func (m *PrivilegedMutator) run(args ...string) error {
+	//nolint:gosec
+	cmd := exec.Command(m.command, append(append([]string{}, m.args...), args...)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := m.m.RunCmd(cmd); err != nil {
+		return fmt.Errorf("%s: %w", ShellQuoteArgs(cmd.Args), err)
+	}
+	return nil
+}
+
+// formatMode returns mode's permission bits as an octal string.
+func formatMode(mode os.FileMode) string {
+	return fmt.Sprintf("%03o", mode&os.ModePerm)
+}
@@ -0,0 +1,97 @@
+// +build !windows
+
+package chezmoi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+var _ Mutator = &PrivilegedMutator{}
+
+func TestPrivilegedMutator(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/etc":       &vfst.Dir{Perm: 0o755},
+		"/home/user": &vfst.Dir{Perm: 0o755},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	logPath, err := fs.RawPath("/log")
+	require.NoError(t, err)
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithEntries(map[string]Entry{
+			".bashrc": &File{
+				targetName: ".bashrc",
+				Perm:       0o644,
+				contents:   []byte("# contents of .bashrc\n"),
+			},
+			"etc": &Dir{
+				targetName: "etc",
+				Perm:       0o755,
+				Entries: map[string]Entry{
+					"hosts": &File{
+						targetName: filepath.Join("etc", "hosts"),
+						Perm:       0o644,
+						contents:   []byte("127.0.0.1 localhost\n"),
+					},
+					"hosts.d": &Dir{
+						targetName: filepath.Join("etc", "hosts.d"),
+						Perm:       0o755,
+						Entries:    map[string]Entry{},
+					},
+				},
+			},
+		}),
+		WithPrivilegedDirs(map[string]string{
+			"etc": "/etc",
+		}),
+	)
+
+	// The helper logs its arguments before running them.
+	mutator := NewPrivilegedMutator(NewFSMutator(fs), fs, "sh", []string{"-c", `echo "$@" >> ` + logPath + `; exec "$@"`, "sh"}, []string{"/etc"})
+	applyOptions := &ApplyOptions{
+		DestDir:        ts.DestDir,
+		Ignore:         func(string) bool { return false },
+		PrivilegedDirs: ts.PrivilegedDirs,
+		Umask:          0o22,
+	}
+	require.NoError(t, ts.Apply(fs, mutator, false, applyOptions))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/etc",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/etc/hosts",
+			vfst.TestModeIsRegular,
+			vfst.TestModePerm(0o644),
+			vfst.TestContentsString("127.0.0.1 localhost\n"),
+		),
+		vfst.TestPath("/etc/hosts.d",
+			vfst.TestIsDir,
+			vfst.TestModePerm(0o755),
+		),
+	)
+
+	log, err := fs.ReadFile("/log")
+	require.NoError(t, err)
+	assert.Contains(t, string(log), "install -m 644 ")
+	assert.Contains(t, string(log), "mkdir -m 755 ")
+	assert.NotContains(t, string(log), ".bashrc")
+
+	// Applying again makes no changes, so the helper is not run.
+	require.NoError(t, fs.Remove("/log"))
+	require.NoError(t, ts.Apply(fs, mutator, false, applyOptions))
+	_, err = fs.Stat("/log")
+	assert.True(t, os.IsNotExist(err))
+}
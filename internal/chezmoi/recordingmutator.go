@@ -0,0 +1,168 @@
+package chezmoi
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// Operations recorded by a RecordingMutator.
+const (
+	OpChmod        = "chmod"
+	OpChown        = "chown"
+	OpMkdir        = "mkdir"
+	OpRemoveAll    = "removeAll"
+	OpRename       = "rename"
+	OpRunScript    = "runScript"
+	OpWriteFile    = "writeFile"
+	OpWriteSymlink = "writeSymlink"
+)
+
+// A RecordedOp is an operation recorded by a RecordingMutator. Fields that do
+// not apply to the operation are empty. OldPerm and OldContentsSHA256 are only
+// set if the path already exists.
+type RecordedOp struct {
+	Op                string `json:"op" yaml:"op"`
+	Path              string `json:"path" yaml:"path"`
+	OldPath           string `json:"oldPath,omitempty" yaml:"oldPath,omitempty"`
+	Linkname          string `json:"linkname,omitempty" yaml:"linkname,omitempty"`
+	Owner             string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	OldPerm           int    `json:"oldPerm,omitempty" yaml:"oldPerm,omitempty"`
+	Perm              int    `json:"perm,omitempty" yaml:"perm,omitempty"`
+	OldContentsSHA256 string `json:"oldContentsSHA256,omitempty" yaml:"oldContentsSHA256,omitempty"`
+	ContentsSHA256    string `json:"contentsSHA256,omitempty" yaml:"contentsSHA256,omitempty"`
+}
+
+// A RecordingMutator wraps a Mutator and records every operation that changes
+// a path, and every script that would run, in order, together with the state
+// of the path in fs before the operation.
+type RecordingMutator struct {
+	m   Mutator
+	fs  vfs.FS
+	ops []RecordedOp
+}
+
+// NewRecordingMutator returns a new RecordingMutator that reads the current
+// state of paths from fs.
+func NewRecordingMutator(m Mutator, fs vfs.FS) *RecordingMutator {
+	return &RecordingMutator{
+		m:   m,
+		fs:  fs,
+		ops: []RecordedOp{},
+	}
+}
+
+// Chmod implements Mutator.Chmod.
+func (m *RecordingMutator) Chmod(name string, mode os.FileMode) error {
+	m.record(RecordedOp{
+		Op:   OpChmod,
+		Path: name,
+		Perm: int(mode.Perm()),
+	})
+	return m.m.Chmod(name, mode)
+}
+
+// Chown implements Mutator.Chown.
+func (m *RecordingMutator) Chown(name string, uid, gid int) error {
+	m.record(RecordedOp{
+		Op:    OpChown,
+		Path:  name,
+		Owner: strconv.Itoa(uid) + ":" + strconv.Itoa(gid),
+	})
+	return m.m.Chown(name, uid, gid)
+}
+
+// IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
+func (m *RecordingMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	return m.m.IdempotentCmdOutput(cmd)
+}
+
+// Mkdir implements Mutator.Mkdir.
+func (m *RecordingMutator) Mkdir(name string, perm os.FileMode) error {
+	m.record(RecordedOp{
+		Op:   OpMkdir,
+		Path: name,
+		Perm: int(perm),
+	})
+	return m.m.Mkdir(name, perm)
+}
+
+// Ops returns the operations recorded by m.
+func (m *RecordingMutator) Ops() []RecordedOp {
+	return m.ops
+}
+
+// RecordScript records that the script at path with contents would run.
+// interpreter and env are ignored.
+func (m *RecordingMutator) RecordScript(path string, contents []byte, interpreter *Interpreter, env []string) {
+	m.record(RecordedOp{
+		Op:             OpRunScript,
+		Path:           path,
+		ContentsSHA256: contentsSHA256(contents),
+	})
+}
+
+// RemoveAll implements Mutator.RemoveAll.
+func (m *RecordingMutator) RemoveAll(name string) error {
+	m.record(RecordedOp{
+		Op:   OpRemoveAll,
+		Path: name,
+	})
+	return m.m.RemoveAll(name)
+}
+
+// Rename implements Mutator.Rename.
+func (m *RecordingMutator) Rename(oldpath, newpath string) error {
+	m.record(RecordedOp{
+		Op:      OpRename,
+		Path:    newpath,
+		OldPath: oldpath,
+	})
+	return m.m.Rename(oldpath, newpath)
+}
+
+// RunCmd implements Mutator.RunCmd.
+func (m *RecordingMutator) RunCmd(cmd *exec.Cmd) error {
+	return m.m.RunCmd(cmd)
+}
+
+// Stat implements Mutator.Stat.
+func (m *RecordingMutator) Stat(name string) (os.FileInfo, error) {
+	return m.m.Stat(name)
+}
+
+// WriteFile implements Mutator.WriteFile.
+func (m *RecordingMutator) WriteFile(name string, data []byte, perm os.FileMode, currData []byte) error {
+	op := RecordedOp{
+		Op:             OpWriteFile,
+		Path:           name,
+		Perm:           int(perm),
+		ContentsSHA256: contentsSHA256(data),
+	}
+	if info, err := m.fs.Lstat(name); err == nil && info.Mode().IsRegular() {
+		op.OldContentsSHA256 = contentsSHA256(currData)
+	}
+	m.record(op)
+	return m.m.WriteFile(name, data, perm, currData)
+}
+
+// WriteSymlink implements Mutator.WriteSymlink.
+func (m *RecordingMutator) WriteSymlink(oldname, newname string) error {
+	m.record(RecordedOp{
+		Op:       OpWriteSymlink,
+		Path:     newname,
+		Linkname: oldname,
+	})
+	return m.m.WriteSymlink(oldname, newname)
+}
+
+// record records op, including the current permissions of op's path if it
+// exists.
+func (m *RecordingMutator) record(op RecordedOp) {
+	if info, err := m.fs.Lstat(op.Path); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		op.OldPerm = int(info.Mode().Perm())
+	}
+	m.ops = append(m.ops, op)
+}
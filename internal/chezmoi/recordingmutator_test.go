@@ -0,0 +1,59 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+var _ Mutator = &RecordingMutator{}
+
+func TestRecordingMutator(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc": &vfst.File{
+				Perm:     0o600,
+				Contents: []byte("# contents of .bashrc\n"),
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	m := NewRecordingMutator(NullMutator{}, fs)
+	require.NoError(t, m.Mkdir("/home/user/.dir", 0o755))
+	require.NoError(t, m.WriteFile("/home/user/.dir/file", []byte("contents"), 0o644, nil))
+	require.NoError(t, m.WriteFile("/home/user/.bashrc", []byte("# edited .bashrc\n"), 0o644, []byte("# contents of .bashrc\n")))
+	require.NoError(t, m.Chmod("/home/user/.bashrc", 0o644))
+	require.NoError(t, m.Chown("/home/user/.bashrc", 1000, 1000))
+	require.NoError(t, m.WriteSymlink("target", "/home/user/.symlink"))
+	require.NoError(t, m.Rename("/home/user/.old", "/home/user/.new"))
+	require.NoError(t, m.RemoveAll("/home/user/.remove"))
+	m.RecordScript("/home/user/script.sh", []byte("#!/bin/sh\n"), nil, nil)
+	_, _ = m.Stat("/home/user/.dir")
+	assert.Equal(t, []RecordedOp{
+		{Op: OpMkdir, Path: "/home/user/.dir", Perm: 0o755},
+		{
+			Op:             OpWriteFile,
+			Path:           "/home/user/.dir/file",
+			Perm:           0o644,
+			ContentsSHA256: contentsSHA256([]byte("contents")),
+		},
+		{
+			Op:                OpWriteFile,
+			Path:              "/home/user/.bashrc",
+			OldPerm:           0o600,
+			Perm:              0o644,
+			OldContentsSHA256: contentsSHA256([]byte("# contents of .bashrc\n")),
+			ContentsSHA256:    contentsSHA256([]byte("# edited .bashrc\n")),
+		},
+		{Op: OpChmod, Path: "/home/user/.bashrc", OldPerm: 0o600, Perm: 0o644},
+		{Op: OpChown, Path: "/home/user/.bashrc", OldPerm: 0o600, Owner: "1000:1000"},
+		{Op: OpWriteSymlink, Path: "/home/user/.symlink", Linkname: "target"},
+		{Op: OpRename, Path: "/home/user/.new", OldPath: "/home/user/.old"},
+		{Op: OpRemoveAll, Path: "/home/user/.remove"},
+		{Op: OpRunScript, Path: "/home/user/script.sh", ContentsSHA256: contentsSHA256([]byte("#!/bin/sh\n"))},
+	}, m.Ops())
+}
@@ -8,22 +8,24 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	vfs "github.com/twpayne/go-vfs"
 )
 
-// FIXME allow encrypted scripts
-// FIXME add pre- and post- attributes
-
 // A ScriptAttributes holds attributes parsed from a source script name.
 type ScriptAttributes struct {
-	Name     string
-	Once     bool
-	Template bool
+	Name         string
+	Encrypted    bool
+	Once         bool
+	OnChange     bool
+	Before       bool
+	After        bool
+	NameTemplate bool
+	Template     bool
 }
 
 // A ScriptState represents the state of a script.
@@ -36,7 +38,11 @@ type ScriptState struct {
 type Script struct {
 	sourceName       string
 	targetName       string
+	Encrypted        bool
 	Once             bool
+	OnChange         bool
+	Before           bool
+	After            bool
 	Template         bool
 	contents         []byte
 	contentsErr      error
@@ -47,7 +53,11 @@ type scriptConcreteValue struct {
 	Type       string `json:"type" yaml:"type"`
 	SourcePath string `json:"sourcePath" yaml:"sourcePath"`
 	TargetPath string `json:"targetPath" yaml:"targetPath"`
+	Encrypted  bool   `json:"encrypted" yaml:"encrypted"`
 	Once       bool   `json:"once" yaml:"once"`
+	OnChange   bool   `json:"onChange" yaml:"onChange"`
+	Before     bool   `json:"before" yaml:"before"`
+	After      bool   `json:"after" yaml:"after"`
 	Template   bool   `json:"template" yaml:"template"`
 	Contents   string `json:"contents" yaml:"contents"`
 }
@@ -55,34 +65,75 @@ type scriptConcreteValue struct {
 // ParseScriptAttributes parses a source script file name.
 func ParseScriptAttributes(sourceName string) ScriptAttributes {
 	name := strings.TrimPrefix(sourceName, runPrefix)
+	encrypted := false
 	once := false
+	onChange := false
+	before := false
+	after := false
 	template := false
-	if strings.HasPrefix(name, oncePrefix) {
+	if strings.HasPrefix(name, encryptedPrefix) {
+		encrypted = true
+		name = strings.TrimPrefix(name, encryptedPrefix)
+	}
+	switch {
+	case strings.HasPrefix(name, oncePrefix):
 		once = true
 		name = strings.TrimPrefix(name, oncePrefix)
+	case strings.HasPrefix(name, onChangePrefix):
+		onChange = true
+		name = strings.TrimPrefix(name, onChangePrefix)
 	}
-	if strings.HasSuffix(name, TemplateSuffix) {
-		template = true
-		name = strings.TrimSuffix(name, TemplateSuffix)
+	switch {
+	case strings.HasPrefix(name, beforePrefix):
+		before = true
+		name = strings.TrimPrefix(name, beforePrefix)
+	case strings.HasPrefix(name, afterPrefix):
+		after = true
+		name = strings.TrimPrefix(name, afterPrefix)
 	}
+	nameTemplate := false
+	if strings.HasPrefix(name, nameTmplPrefix) {
+		name = strings.TrimPrefix(name, nameTmplPrefix)
+		nameTemplate = true
+	}
+	name, template = parseNameSuffix(strings.TrimPrefix(name, literalPrefix))
 	return ScriptAttributes{
-		Name:     name,
-		Once:     once,
-		Template: template,
+		Name:         name,
+		Encrypted:    encrypted,
+		Once:         once,
+		OnChange:     onChange,
+		Before:       before,
+		After:        after,
+		NameTemplate: nameTemplate,
+		Template:     template,
 	}
 }
 
 // SourceName returns sa's source name.
 func (sa ScriptAttributes) SourceName() string {
 	sourceName := runPrefix
-	if sa.Once {
+	if sa.Encrypted {
+		sourceName += encryptedPrefix
+	}
+	switch {
+	case sa.Once:
 		sourceName += oncePrefix
+	case sa.OnChange:
+		sourceName += onChangePrefix
 	}
-	sourceName += sa.Name
-	if sa.Template {
-		sourceName += TemplateSuffix
+	switch {
+	case sa.Before:
+		sourceName += beforePrefix
+	case sa.After:
+		sourceName += afterPrefix
 	}
-	return sourceName
+	if sa.NameTemplate {
+		sourceName += nameTmplPrefix
+	}
+	if hasAttributePrefix(sa.Name) {
+		sourceName += literalPrefix
+	}
+	return formatNameSuffix(sourceName+sa.Name, sa.Name, sa.Template)
 }
 
 // AppendAllEntries returns allEntries unchanged.
@@ -103,10 +154,18 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 		return nil
 	}
 
+	// Scripts that run once are keyed by their target name. Scripts that run
+	// when their contents change are keyed by their target name and the
+	// SHA256 of their contents.
 	var key []byte
-	if s.Once {
+	switch {
+	case s.Once:
+		key = []byte(s.targetName)
+	case s.OnChange:
 		contentsKeyArr := sha256.Sum256(contents)
 		key = []byte(s.targetName + ":" + hex.EncodeToString(contentsKeyArr[:]))
+	}
+	if key != nil {
 		scriptStateData, err := applyOptions.PersistentState.Get(applyOptions.ScriptStateBucket, key)
 		if err != nil {
 			return err
@@ -116,6 +175,10 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 		}
 	}
 
+	if applyOptions.RecordScript != nil {
+		targetPath := applyOptions.TargetPath(s.targetName)
+		applyOptions.RecordScript(targetPath, contents, findInterpreter(applyOptions.Interpreters, s.targetName), scriptVars(applyOptions.ScriptEnv, targetPath))
+	}
 	if applyOptions.Verbose {
 		if _, err := applyOptions.Stdout.Write(contents); err != nil {
 			return err
@@ -125,6 +188,18 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 		return nil
 	}
 
+	// Run the script in the target's parent directory if it exists, otherwise
+	// in the destination directory. Scripts that run before other entries are
+	// applied may run before their parent directory is created.
+	dir := filepath.Dir(applyOptions.TargetPath(s.targetName))
+	if info, err := fs.Stat(dir); err != nil || !info.IsDir() {
+		dir = applyOptions.DestDir
+	}
+	rawDir, err := fs.RawPath(dir)
+	if err != nil {
+		return err
+	}
+
 	// Write the temporary script file. Put the randomness on the front of the
 	// filename to preserve any file extension for Windows scripts.
 	f, err := ioutil.TempFile("", "*."+filepath.Base(s.targetName))
@@ -146,9 +221,9 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 	}
 
 	// Run the temporary script file.
-	//nolint:gosec
-	c := exec.Command(f.Name())
-	c.Dir = filepath.Join(applyOptions.DestDir, filepath.Dir(s.targetName))
+	c := findInterpreter(applyOptions.Interpreters, s.targetName).ExecCommand(f.Name())
+	c.Dir = rawDir
+	c.Env = scriptEnv(applyOptions.ScriptEnv, applyOptions.TargetPath(s.targetName))
 	c.Stdout = os.Stdout
 	c.Stderr = os.Stderr
 	c.Stdin = os.Stdin
@@ -156,7 +231,7 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 		return err
 	}
 
-	if s.Once {
+	if key != nil {
 		scriptState := &ScriptState{
 			Name:       s.sourceName,
 			ExecutedAt: time.Now(),
@@ -174,7 +249,7 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (s *Script) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
+func (s *Script) ConcreteValue(ignore func(string) bool, sourceDir func(Entry) string, umask os.FileMode, recursive bool) (interface{}, error) {
 	if ignore(s.targetName) {
 		return nil, nil
 	}
@@ -184,9 +259,13 @@ func (s *Script) ConcreteValue(ignore func(string) bool, sourceDir string, umask
 	}
 	return &scriptConcreteValue{
 		Type:       "script",
-		SourcePath: filepath.Join(sourceDir, s.SourceName()),
+		SourcePath: filepath.Join(sourceDir(s), s.SourceName()),
 		TargetPath: s.TargetName(),
+		Encrypted:  s.Encrypted,
 		Once:       s.Once,
+		OnChange:   s.OnChange,
+		Before:     s.Before,
+		After:      s.After,
 		Template:   s.Template,
 		Contents:   string(contents),
 	}, nil
@@ -240,3 +319,59 @@ func (s *Script) archive(w *tar.Writer, ignore func(string) bool, headerTemplate
 	_, err = w.Write(contents)
 	return err
 }
+
+// appendPhaseScripts appends all scripts in entry and its descendants that
+// should be run before and after other entries are applied to beforeScripts
+// and afterScripts respectively.
+func appendPhaseScripts(beforeScripts, afterScripts []*Script, entry Entry, ignore func(string) bool) ([]*Script, []*Script) {
+	switch entry := entry.(type) {
+	case *Dir:
+		if ignore(entry.targetName) {
+			break
+		}
+		for _, entryName := range sortedEntryNames(entry.Entries) {
+			beforeScripts, afterScripts = appendPhaseScripts(beforeScripts, afterScripts, entry.Entries[entryName], ignore)
+		}
+	case *Script:
+		switch {
+		case entry.Before:
+			beforeScripts = append(beforeScripts, entry)
+		case entry.After:
+			afterScripts = append(afterScripts, entry)
+		}
+	}
+	return beforeScripts, afterScripts
+}
+
+// isPhaseScript returns true if entry is a script that should be run before or
+// after other entries are applied.
+func isPhaseScript(entry Entry) bool {
+	s, ok := entry.(*Script)
+	return ok && (s.Before || s.After)
+}
+
+// sortScripts sorts scripts by target name.
+func sortScripts(scripts []*Script) {
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].targetName < scripts[j].targetName
+	})
+}
+
+// scriptEnv returns the environment for a script with target path targetPath,
+// consisting of the current environment and the variables returned by
+// scriptVars.
+func scriptEnv(env []string, targetPath string) []string {
+	vars := scriptVars(env, targetPath)
+	environ := os.Environ()
+	result := make([]string, 0, len(environ)+len(vars))
+	result = append(result, environ...)
+	return append(result, vars...)
+}
+
+// scriptVars returns the variables that chezmoi sets for a script with target
+// path targetPath, consisting of env and CHEZMOI_TARGET_PATH.
+func scriptVars(env []string, targetPath string) []string {
+	result := make([]string, 0, len(env)+1)
+	result = append(result, env...)
+	return append(result, "CHEZMOI_TARGET_PATH="+targetPath)
+}
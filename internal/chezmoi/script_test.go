@@ -0,0 +1,100 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptAttributes(t *testing.T) {
+	for _, tc := range []struct {
+		sourceName string
+		sa         ScriptAttributes
+	}{
+		{
+			sourceName: "run_foo",
+			sa: ScriptAttributes{
+				Name: "foo",
+			},
+		},
+		{
+			sourceName: "run_once_foo",
+			sa: ScriptAttributes{
+				Name: "foo",
+				Once: true,
+			},
+		},
+		{
+			sourceName: "run_onchange_foo",
+			sa: ScriptAttributes{
+				Name:     "foo",
+				OnChange: true,
+			},
+		},
+		{
+			sourceName: "run_foo.tmpl",
+			sa: ScriptAttributes{
+				Name:     "foo",
+				Template: true,
+			},
+		},
+		{
+			sourceName: "run_encrypted_foo",
+			sa: ScriptAttributes{
+				Name:      "foo",
+				Encrypted: true,
+			},
+		},
+		{
+			sourceName: "run_before_foo",
+			sa: ScriptAttributes{
+				Name:   "foo",
+				Before: true,
+			},
+		},
+		{
+			sourceName: "run_once_after_foo",
+			sa: ScriptAttributes{
+				Name:  "foo",
+				Once:  true,
+				After: true,
+			},
+		},
+		{
+			sourceName: "run_encrypted_once_foo.tmpl",
+			sa: ScriptAttributes{
+				Name:      "foo",
+				Encrypted: true,
+				Once:      true,
+				Template:  true,
+			},
+		},
+		{
+			sourceName: "run_once_nametmpl_{{ .host }}.sh",
+			sa: ScriptAttributes{
+				Name:         "{{ .host }}.sh",
+				Once:         true,
+				NameTemplate: true,
+			},
+		},
+		{
+			sourceName: "run_literal_once_foo",
+			sa: ScriptAttributes{
+				Name: "once_foo",
+			},
+		},
+		{
+			sourceName: "run_once_literal_after_foo.tmpl.literal.tmpl",
+			sa: ScriptAttributes{
+				Name:     "after_foo.tmpl",
+				Once:     true,
+				Template: true,
+			},
+		},
+	} {
+		t.Run(tc.sourceName, func(t *testing.T) {
+			assert.Equal(t, tc.sa, ParseScriptAttributes(tc.sourceName))
+			assert.Equal(t, tc.sourceName, tc.sa.SourceName())
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package chezmoi
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// shellScriptHeader is the start of every script written by a
+// ShellScriptMutator. Scripts are written to a temporary directory before they
+// are run.
+const shellScriptHeader = `#!/bin/sh
+
+# This script was generated by chezmoi apply --export-script.
+
+set -e
+
+scripts="${TMPDIR:-/tmp}/chezmoi-scripts.$$"
+mkdir -m 0700 "$scripts"
+trap 'rm -rf "$scripts"' EXIT
+`
+
+// A ShellScriptMutator wraps a Mutator and records every operation that
+// changes a path, and every script that would run, as a POSIX shell script
+// that makes the same changes.
+type ShellScriptMutator struct {
+	m       Mutator
+	destDir string
+	buf     bytes.Buffer
+}
+
+// NewShellScriptMutator returns a new ShellScriptMutator. Scripts whose target
+// directories do not exist are run in destDir.
+func NewShellScriptMutator(m Mutator, destDir string) *ShellScriptMutator {
+	return &ShellScriptMutator{
+		m:       m,
+		destDir: destDir,
+	}
+}
+
+// Chmod implements Mutator.Chmod.
+func (m *ShellScriptMutator) Chmod(name string, mode os.FileMode) error {
+	fmt.Fprintf(&m.buf, "chmod %04o %s\n", mode.Perm(), MaybeShellQuote(name))
+	return m.m.Chmod(name, mode)
+}
+
+// Chown implements Mutator.Chown.
+func (m *ShellScriptMutator) Chown(name string, uid, gid int) error {
+	fmt.Fprintf(&m.buf, "chown %d:%d %s\n", uid, gid, MaybeShellQuote(name))
+	return m.m.Chown(name, uid, gid)
+}
+
+// IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
+func (m *ShellScriptMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	return m.m.IdempotentCmdOutput(cmd)
+}
+
+// Mkdir implements Mutator.Mkdir.
+func (m *ShellScriptMutator) Mkdir(name string, perm os.FileMode) error {
+	fmt.Fprintf(&m.buf, "mkdir -m %04o %s\n", perm, MaybeShellQuote(name))
+	return m.m.Mkdir(name, perm)
+}
+
+// RecordScript records that the script at path with contents would run with
+// interpreter and the extra environment variables env. The script keeps the
+// name of path, and so its extension, and is run in the directory containing
+// path, or in m's destination directory if that does not exist.
+func (m *ShellScriptMutator) RecordScript(path string, contents []byte, interpreter *Interpreter, env []string) {
+	scriptPath := `"$scripts"/` + MaybeShellQuote(filepath.Base(path))
+	m.writeContents(scriptPath, contents)
+	fmt.Fprintf(&m.buf, "chmod 0700 %s\n(\n", scriptPath)
+	fmt.Fprintf(&m.buf, "cd %s 2>/dev/null || cd %s\n", MaybeShellQuote(filepath.Dir(path)), MaybeShellQuote(m.destDir))
+	for _, keyValue := range env {
+		if index := strings.IndexByte(keyValue, '='); index > 0 {
+			fmt.Fprintf(&m.buf, "export %s=%s\n", keyValue[:index], shellQuote(keyValue[index+1:]))
+		}
+	}
+	args := []string{scriptPath}
+	if interpreter != nil && interpreter.Command != "" {
+		args = []string{MaybeShellQuote(interpreter.Command)}
+		for _, arg := range interpreter.Args {
+			args = append(args, MaybeShellQuote(arg))
+		}
+		args = append(args, scriptPath)
+	}
+	fmt.Fprintf(&m.buf, "%s\n)\n", strings.Join(args, " "))
+}
+
+// RemoveAll implements Mutator.RemoveAll.
+func (m *ShellScriptMutator) RemoveAll(name string) error {
+	fmt.Fprintf(&m.buf, "rm -rf %s\n", MaybeShellQuote(name))
+	return m.m.RemoveAll(name)
+}
+
+// Rename implements Mutator.Rename.
+func (m *ShellScriptMutator) Rename(oldpath, newpath string) error {
+	fmt.Fprintf(&m.buf, "mv -f %s %s\n", MaybeShellQuote(oldpath), MaybeShellQuote(newpath))
+	return m.m.Rename(oldpath, newpath)
+}
+
+// RunCmd implements Mutator.RunCmd.
+func (m *ShellScriptMutator) RunCmd(cmd *exec.Cmd) error {
+	return m.m.RunCmd(cmd)
+}
+
+// Script returns the shell script recorded by m.
+func (m *ShellScriptMutator) Script() []byte {
+	return append([]byte(shellScriptHeader), m.buf.Bytes()...)
+}
+
+// Stat implements Mutator.Stat.
+func (m *ShellScriptMutator) Stat(name string) (os.FileInfo, error) {
+	return m.m.Stat(name)
+}
+
+// WriteFile implements Mutator.WriteFile.
+func (m *ShellScriptMutator) WriteFile(name string, data []byte, perm os.FileMode, currData []byte) error {
+	m.writeContents(MaybeShellQuote(name), data)
+	fmt.Fprintf(&m.buf, "chmod %04o %s\n", perm, MaybeShellQuote(name))
+	return m.m.WriteFile(name, data, perm, currData)
+}
+
+// WriteSymlink implements Mutator.WriteSymlink.
+func (m *ShellScriptMutator) WriteSymlink(oldname, newname string) error {
+	fmt.Fprintf(&m.buf, "rm -f %s\nln -s %s %s\n", MaybeShellQuote(newname), MaybeShellQuote(oldname), MaybeShellQuote(newname))
+	return m.m.WriteSymlink(oldname, newname)
+}
+
+// writeContents writes a command that writes data to target, which must
+// already be quoted. Text that ends with a newline is written with a here
+// document, everything else with printf.
+func (m *ShellScriptMutator) writeContents(target string, data []byte) {
+	switch {
+	case len(data) == 0:
+		fmt.Fprintf(&m.buf, ": > %s\n", target)
+	case utf8.Valid(data) && bytes.IndexByte(data, 0) == -1 && data[len(data)-1] == '\n':
+		delimiter := hereDocDelimiter(data)
+		fmt.Fprintf(&m.buf, "cat > %s <<'%s'\n%s%s\n", target, delimiter, data, delimiter)
+	default:
+		fmt.Fprintf(&m.buf, "printf '%s' > %s\n", printfEscape(data), target)
+	}
+}
+
+// hereDocDelimiter returns a here document delimiter that does not occur as a
+// line in data.
+func hereDocDelimiter(data []byte) string {
+	delimiter := "EOF"
+	for i := 1; bytes.Contains(append([]byte{'\n'}, data...), []byte("\n"+delimiter+"\n")); i++ {
+		delimiter = "EOF" + strconv.Itoa(i)
+	}
+	return delimiter
+}
+
+// shellQuote returns s quoted for a POSIX shell. Unlike MaybeShellQuote, it
+// always quotes s and leaves backslashes unchanged, so that arbitrary values
+// are preserved exactly.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// printfEscape returns data escaped as a printf format inside single quotes.
+func printfEscape(data []byte) string {
+	buf := bytes.Buffer{}
+	for _, b := range data {
+		switch {
+		case b == '%':
+			buf.WriteString("%%")
+		case b == '\\' || b == '\'' || b < ' ' || b > '~':
+			fmt.Fprintf(&buf, "\\%03o", b)
+		default:
+			buf.WriteByte(b)
+		}
+	}
+	return buf.String()
+}
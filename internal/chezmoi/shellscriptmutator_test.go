@@ -0,0 +1,71 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ Mutator = &ShellScriptMutator{}
+
+func TestShellScriptMutator(t *testing.T) {
+	m := NewShellScriptMutator(NullMutator{}, "/home/user")
+	require.NoError(t, m.Mkdir("/home/user/.dir", 0o755))
+	require.NoError(t, m.WriteFile("/home/user/.dir/file", []byte("# contents of .dir/file\n"), 0o644, nil))
+	require.NoError(t, m.WriteFile("/home/user/.empty", nil, 0o644, nil))
+	require.NoError(t, m.WriteFile("/home/user/.eof", []byte("EOF\n"), 0o600, nil))
+	require.NoError(t, m.WriteFile("/home/user/.binary", []byte("a'%\\\x00"), 0o644, nil))
+	require.NoError(t, m.Chmod("/home/user/.dir", 0o700))
+	require.NoError(t, m.Chown("/home/user/.dir", 1000, 1000))
+	require.NoError(t, m.WriteSymlink(".dir/file", "/home/user/.symlink"))
+	require.NoError(t, m.Rename("/home/user/.old", "/home/user/.new"))
+	require.NoError(t, m.RemoveAll("/home/user/.remove"))
+	m.RecordScript("/home/user/script.sh", []byte("#!/bin/sh\n"), nil, []string{
+		"CHEZMOI_TARGET_PATH=/home/user/script.sh",
+	})
+	m.RecordScript("/home/user/.dir/script.py", []byte("print('hello')\n"), &Interpreter{Command: "python3", Args: []string{"-u"}}, []string{
+		`CHEZMOI_DATA={"a":"b\c'd"}`,
+		"CHEZMOI_TARGET_PATH=/home/user/.dir/script.py",
+	})
+	assert.Equal(t, shellScriptHeader+
+		"mkdir -m 0755 /home/user/.dir\n"+
+		"cat > /home/user/.dir/file <<'EOF'\n"+
+		"# contents of .dir/file\n"+
+		"EOF\n"+
+		"chmod 0644 /home/user/.dir/file\n"+
+		": > /home/user/.empty\n"+
+		"chmod 0644 /home/user/.empty\n"+
+		"cat > /home/user/.eof <<'EOF1'\n"+
+		"EOF\n"+
+		"EOF1\n"+
+		"chmod 0600 /home/user/.eof\n"+
+		"printf 'a\\047%%\\134\\000' > /home/user/.binary\n"+
+		"chmod 0644 /home/user/.binary\n"+
+		"chmod 0700 /home/user/.dir\n"+
+		"chown 1000:1000 /home/user/.dir\n"+
+		"rm -f /home/user/.symlink\n"+
+		"ln -s .dir/file /home/user/.symlink\n"+
+		"mv -f /home/user/.old /home/user/.new\n"+
+		"rm -rf /home/user/.remove\n"+
+		"cat > \"$scripts\"/script.sh <<'EOF'\n"+
+		"#!/bin/sh\n"+
+		"EOF\n"+
+		"chmod 0700 \"$scripts\"/script.sh\n"+
+		"(\n"+
+		"cd /home/user 2>/dev/null || cd /home/user\n"+
+		"export CHEZMOI_TARGET_PATH='/home/user/script.sh'\n"+
+		"\"$scripts\"/script.sh\n"+
+		")\n"+
+		"cat > \"$scripts\"/script.py <<'EOF'\n"+
+		"print('hello')\n"+
+		"EOF\n"+
+		"chmod 0700 \"$scripts\"/script.py\n"+
+		"(\n"+
+		"cd /home/user/.dir 2>/dev/null || cd /home/user\n"+
+		`export CHEZMOI_DATA='{"a":"b\c'\''d"}'`+"\n"+
+		"export CHEZMOI_TARGET_PATH='/home/user/.dir/script.py'\n"+
+		"python3 -u \"$scripts\"/script.py\n"+
+		")\n",
+		string(m.Script()))
+}
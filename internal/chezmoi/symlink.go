@@ -41,7 +41,7 @@ func (s *Symlink) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *A
 	if err != nil {
 		return err
 	}
-	targetPath := filepath.Join(applyOptions.DestDir, s.targetName)
+	targetPath := applyOptions.TargetPath(s.targetName)
 	var info os.FileInfo
 	if follow {
 		info, err = fs.Stat(targetPath)
@@ -70,7 +70,7 @@ func (s *Symlink) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *A
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (s *Symlink) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
+func (s *Symlink) ConcreteValue(ignore func(string) bool, sourceDir func(Entry) string, umask os.FileMode, recursive bool) (interface{}, error) {
 	if ignore(s.targetName) {
 		return nil, nil
 	}
@@ -80,7 +80,7 @@ func (s *Symlink) ConcreteValue(ignore func(string) bool, sourceDir string, umas
 	}
 	return &symlinkConcreteValue{
 		Type:       "symlink",
-		SourcePath: filepath.Join(sourceDir, s.SourceName()),
+		SourcePath: filepath.Join(sourceDir(s), s.SourceName()),
 		TargetPath: s.TargetName(),
 		Template:   s.Template,
 		Linkname:   linkname,
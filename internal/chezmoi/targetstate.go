@@ -4,12 +4,14 @@ import (
 	"archive/tar"
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 	"text/template"
@@ -23,6 +25,8 @@ import (
 var DefaultTemplateOptions = []string{"missingkey=error"}
 
 const (
+	dataName         = ".chezmoidata"
+	externalName     = ".chezmoiexternal"
 	ignoreName       = ".chezmoiignore"
 	removeName       = ".chezmoiremove"
 	templatesDirName = ".chezmoitemplates"
@@ -31,6 +35,7 @@ const (
 
 // An AddOptions contains options for TargetState.Add.
 type AddOptions struct {
+	Create       bool
 	Empty        bool
 	Encrypt      bool
 	Exact        bool
@@ -53,23 +58,47 @@ type PopulateOptions struct {
 
 // A TargetState represents the root target state.
 type TargetState struct {
-	DestDir         string
-	Entries         map[string]Entry
-	GPG             *GPG
-	MinVersion      *semver.Version
-	SourceDir       string
-	TargetIgnore    *PatternSet
-	TargetRemove    *PatternSet
-	TemplateData    map[string]interface{}
-	TemplateFuncs   template.FuncMap
-	TemplateOptions []string
-	Templates       map[string]*template.Template
-	Umask           os.FileMode
+	CacheDir         string
+	CacheFS          vfs.FS
+	DestDir          string
+	DryRun           bool
+	Entries          map[string]Entry
+	GPG              *GPG
+	Interpreters     map[string]*Interpreter
+	MinVersion       *semver.Version
+	PrivilegedDirs   map[string]string
+	RefreshExternals bool
+	SourceDir        string
+	SourceDirs       []string
+	TargetIgnore     *PatternSet
+	TargetRemove     *PatternSet
+	TemplateData     map[string]interface{}
+	TemplateFuncs    template.FuncMap
+	TemplateOptions  []string
+	Templates        map[string]*template.Template
+	Umask            os.FileMode
+	attributeRules   []*attributeRule
+	entrySourceDirs  map[string]string
 }
 
 // A TargetStateOption sets an option on a TargeState.
 type TargetStateOption func(*TargetState)
 
+// WithCacheDir sets the cache directory.
+func WithCacheDir(cacheDir string) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.CacheDir = cacheDir
+	}
+}
+
+// WithCacheFS sets the filesystem used for the cache directory. If it is not
+// set then the filesystem passed to Populate is used.
+func WithCacheFS(cacheFS vfs.FS) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.CacheFS = cacheFS
+	}
+}
+
 // WithDestDir sets DestDir.
 func WithDestDir(destDir string) TargetStateOption {
 	return func(ts *TargetState) {
@@ -77,6 +106,13 @@ func WithDestDir(destDir string) TargetStateOption {
 	}
 }
 
+// WithDryRun sets whether the cache directory should be left unchanged.
+func WithDryRun(dryRun bool) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.DryRun = dryRun
+	}
+}
+
 // WithEntries sets the entries.
 func WithEntries(entries map[string]Entry) TargetStateOption {
 	return func(ts *TargetState) {
@@ -91,6 +127,13 @@ func WithGPG(gpg *GPG) TargetStateOption {
 	}
 }
 
+// WithInterpreters sets the script interpreters.
+func WithInterpreters(interpreters map[string]*Interpreter) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.Interpreters = interpreters
+	}
+}
+
 // WithMinVersion sets the minimum version.
 func WithMinVersion(minVersion *semver.Version) TargetStateOption {
 	return func(ts *TargetState) {
@@ -98,6 +141,23 @@ func WithMinVersion(minVersion *semver.Version) TargetStateOption {
 	}
 }
 
+// WithPrivilegedDirs sets the privileged directories. privilegedDirs maps
+// target names to the absolute paths of directories outside the destination
+// directory.
+func WithPrivilegedDirs(privilegedDirs map[string]string) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.PrivilegedDirs = privilegedDirs
+	}
+}
+
+// WithRefreshExternals sets whether externals should be refreshed regardless
+// of their refresh periods.
+func WithRefreshExternals(refreshExternals bool) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.RefreshExternals = refreshExternals
+	}
+}
+
 // WithSourceDir sets the source directory.
 func WithSourceDir(sourceDir string) TargetStateOption {
 	return func(ts *TargetState) {
@@ -105,6 +165,14 @@ func WithSourceDir(sourceDir string) TargetStateOption {
 	}
 }
 
+// WithSourceDirs sets the source directories, in the order in which they are
+// read.
+func WithSourceDirs(sourceDirs []string) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.SourceDirs = sourceDirs
+	}
+}
+
 // WithTargetIgnore sets the target patterns to ignore.
 func WithTargetIgnore(targetIgnore *PatternSet) TargetStateOption {
 	return func(ts *TargetState) {
@@ -170,14 +238,7 @@ func NewTargetState(options ...TargetStateOption) *TargetState {
 
 // Add adds a new target to ts.
 func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string, info os.FileInfo, follow bool, mutator Mutator) error {
-	contains, err := vfs.Contains(fs, targetPath, ts.DestDir)
-	if err != nil {
-		return err
-	}
-	if !contains {
-		return fmt.Errorf("%s: outside target directory", targetPath)
-	}
-	targetName, err := filepath.Rel(ts.DestDir, targetPath)
+	targetName, err := relTargetName(fs, ts.DestDir, ts.PrivilegedDirs, targetPath)
 	if err != nil {
 		return err
 	}
@@ -198,6 +259,19 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 		}
 	}
 
+	// Targets that are symlinks to their own source files, as created in
+	// symlink mode, are already up to date in the source state.
+	if info.Mode()&os.ModeType == os.ModeSymlink {
+		entry, _ := ts.findEntry(targetName)
+		if file, ok := entry.(*File); ok {
+			if linked, err := file.linkedFrom(fs, targetPath); err != nil {
+				return err
+			} else if linked {
+				return nil
+			}
+		}
+	}
+
 	// Add the parent directories, if needed.
 	parentDirSourceName := ""
 	entries := ts.Entries
@@ -207,7 +281,7 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 			return err
 		}
 		if parentEntry == nil {
-			if err := ts.Add(fs, addOptions, filepath.Join(ts.DestDir, parentDirName), nil, follow, mutator); err != nil {
+			if err := ts.Add(fs, addOptions, ts.TargetPath(parentDirName), nil, follow, mutator); err != nil {
 				return err
 			}
 			parentEntry, err = ts.findEntry(parentDirName)
@@ -248,6 +322,9 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 			case os.IsNotExist(err):
 				return nil
 			case err == nil:
+				if ts.EntrySourceDir(entry) != ts.SourceDir {
+					return nil
+				}
 				return mutator.RemoveAll(filepath.Join(ts.SourceDir, entry.SourceName()))
 			default:
 				return err
@@ -274,7 +351,7 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 		if private {
 			perm &^= 0o77
 		}
-		return ts.addFile(targetName, entries, parentDirSourceName, info, perm, addOptions.Encrypt, addOptions.Template, contents, mutator)
+		return ts.addFile(targetName, entries, parentDirSourceName, info, perm, addOptions.Create, addOptions.Encrypt, addOptions.Template, contents, mutator)
 	case info.Mode()&os.ModeType == os.ModeSymlink:
 		linkname, err := fs.Readlink(targetPath)
 		if err != nil {
@@ -298,54 +375,23 @@ func (ts *TargetState) AllEntries() []Entry {
 // Apply ensures that ts.DestDir in fs matches ts.
 func (ts *TargetState) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error {
 	if applyOptions.Remove {
-		// Build a set of targets to remove.
-		targetsToRemove := make(map[string]struct{})
-		includes := make([]string, 0, len(ts.TargetRemove.includes))
-		for include := range ts.TargetRemove.includes {
-			includes = append(includes, include)
-		}
-		for _, include := range includes {
-			matches, err := doublestar.GlobOS(doubleStarOS{FS: fs}, filepath.Join(ts.DestDir, include))
-			if err != nil {
-				return err
-			}
-			for _, match := range matches {
-				relPath := strings.TrimPrefix(match, ts.DestDir+string(filepath.Separator))
-				// Don't remove targets that are ignored.
-				if ts.TargetIgnore.Match(relPath) {
-					continue
-				}
-				// Don't remove targets that are excluded from remove.
-				if !ts.TargetRemove.Match(relPath) {
-					continue
-				}
-				targetsToRemove[match] = struct{}{}
-			}
-		}
-
-		// FIXME check that the set of targets to remove does not intersect wth
-		// the list of all entries.
-
-		// Remove targets in reverse order so we remove children before their
-		// parents.
-		sortedTargetsToRemove := make([]string, 0, len(targetsToRemove))
-		for target := range targetsToRemove {
-			sortedTargetsToRemove = append(sortedTargetsToRemove, target)
+		targetsToRemove, err := ts.TargetsToRemove(fs)
+		if err != nil {
+			return err
 		}
-		sort.Sort(sort.Reverse(sort.StringSlice(sortedTargetsToRemove)))
-		for _, target := range sortedTargetsToRemove {
+		for _, target := range targetsToRemove {
 			if err := mutator.RemoveAll(target); err != nil {
 				return err
 			}
 		}
 	}
 
-	for _, entryName := range sortedEntryNames(ts.Entries) {
-		if err := ts.Entries[entryName].Apply(fs, mutator, follow, applyOptions); err != nil {
-			return err
-		}
+	entryNames := sortedEntryNames(ts.Entries)
+	entries := make([]Entry, 0, len(entryNames))
+	for _, entryName := range entryNames {
+		entries = append(entries, ts.Entries[entryName])
 	}
-	return nil
+	return ApplyEntries(fs, mutator, follow, applyOptions, entries)
 }
 
 // Archive writes ts to w.
@@ -367,7 +413,7 @@ func (ts *TargetState) Archive(w *tar.Writer, umask os.FileMode) error {
 func (ts *TargetState) ConcreteValue(recursive bool) (interface{}, error) {
 	var entryConcreteValues []interface{}
 	for _, entryName := range sortedEntryNames(ts.Entries) {
-		entryConcreteValue, err := ts.Entries[entryName].ConcreteValue(ts.TargetIgnore.Match, ts.SourceDir, ts.Umask, recursive)
+		entryConcreteValue, err := ts.Entries[entryName].ConcreteValue(ts.TargetIgnore.Match, ts.EntrySourceDir, ts.Umask, recursive)
 		if err != nil {
 			return nil, err
 		}
@@ -407,16 +453,56 @@ func (ts *TargetState) ExecuteTemplateData(name string, data []byte) ([]byte, er
 	return []byte(sb.String()), nil
 }
 
-// Get returns the state of the given target, or nil if no such target is found.
-func (ts *TargetState) Get(fs vfs.Stater, target string) (Entry, error) {
-	contains, err := vfs.Contains(fs, target, ts.DestDir)
-	if err != nil {
-		return nil, err
+// TargetPath returns the path of targetName, taking into account any
+// privileged directories.
+func (ts *TargetState) TargetPath(targetName string) string {
+	return joinTargetPath(ts.DestDir, ts.PrivilegedDirs, targetName)
+}
+
+// TargetsToRemove returns the targets in fs that match .chezmoiremove and are
+// not ignored, children before their parents.
+func (ts *TargetState) TargetsToRemove(fs vfs.FS) ([]string, error) {
+	// Build a set of targets to remove.
+	targetsToRemove := make(map[string]struct{})
+	includes := make([]string, 0, len(ts.TargetRemove.includes))
+	for include := range ts.TargetRemove.includes {
+		includes = append(includes, include)
+	}
+	for _, include := range includes {
+		matches, err := doublestar.GlobOS(doubleStarOS{FS: fs}, filepath.Join(ts.DestDir, include))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			relPath := strings.TrimPrefix(match, ts.DestDir+string(filepath.Separator))
+			// Don't remove targets that are ignored.
+			if ts.TargetIgnore.Match(relPath) {
+				continue
+			}
+			// Don't remove targets that are excluded from remove.
+			if !ts.TargetRemove.Match(relPath) {
+				continue
+			}
+			targetsToRemove[match] = struct{}{}
+		}
 	}
-	if !contains {
-		return nil, fmt.Errorf("%s: outside target directory", target)
+
+	// FIXME check that the set of targets to remove does not intersect wth
+	// the list of all entries.
+
+	// Sort targets in reverse order so that children are removed before their
+	// parents.
+	sortedTargetsToRemove := make([]string, 0, len(targetsToRemove))
+	for target := range targetsToRemove {
+		sortedTargetsToRemove = append(sortedTargetsToRemove, target)
 	}
-	targetName, err := filepath.Rel(ts.DestDir, target)
+	sort.Sort(sort.Reverse(sort.StringSlice(sortedTargetsToRemove)))
+	return sortedTargetsToRemove, nil
+}
+
+// Get returns the state of the given target, or nil if no such target is found.
+func (ts *TargetState) Get(fs vfs.Stater, target string) (Entry, error) {
+	targetName, err := relTargetName(fs, ts.DestDir, ts.PrivilegedDirs, target)
 	if err != nil {
 		return nil, err
 	}
@@ -445,10 +531,80 @@ func (ts *TargetState) ImportTAR(r *tar.Reader, importTAROptions ImportTAROption
 	return nil
 }
 
-// Populate walks fs from ts.SourceDir to populate ts.
+// EntrySourceDir returns the source directory that entry was read from.
+func (ts *TargetState) EntrySourceDir(entry Entry) string {
+	for name := entry.TargetName(); name != "."; name = filepath.Dir(name) {
+		if sourceDir, ok := ts.entrySourceDirs[name]; ok {
+			return sourceDir
+		}
+	}
+	return ts.SourceDir
+}
+
+// Populate walks fs from each of ts's source directories in turn to populate
+// ts. Entries in later source directories override entries with the same
+// target name in earlier source directories.
 func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
-	return vfs.Walk(fs, ts.SourceDir, func(path string, info os.FileInfo, _ error) error {
-		relPath, err := filepath.Rel(ts.SourceDir, path)
+	sourceDirs := ts.SourceDirs
+	if len(sourceDirs) == 0 {
+		sourceDirs = []string{ts.SourceDir}
+	} else if len(sourceDirs) > 1 {
+		ts.entrySourceDirs = make(map[string]string)
+	}
+
+	// Read all template data before any template is executed.
+	if err := ts.addTemplateData(fs, sourceDirs); err != nil {
+		return err
+	}
+
+	// Read all attributes before any entries are added.
+	if err := ts.addAttributesFiles(fs, sourceDirs); err != nil {
+		return err
+	}
+
+	var externalFiles []externalFile
+	for _, sourceDir := range sourceDirs {
+		sourceDirExternalFiles, err := ts.populateSourceDir(fs, sourceDir, options)
+		if err != nil {
+			return err
+		}
+		externalFiles = append(externalFiles, sourceDirExternalFiles...)
+	}
+
+	// Externals are templates, so they can only be added if templates are
+	// executed.
+	if options != nil && !options.ExecuteTemplates {
+		return nil
+	}
+	for _, ef := range externalFiles {
+		if err := ts.addExternals(fs, ef.sourceDir, ef.relPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// populateSourceDir walks fs from sourceDir to add the entries in sourceDir
+// to ts. It returns the external files in sourceDir, which are added after
+// all other entries.
+func (ts *TargetState) populateSourceDir(fs vfs.FS, sourceDir string, options *PopulateOptions) ([]externalFile, error) {
+	var externalFiles []externalFile
+	// Entries with name templates in the same source directory must not
+	// render to the same target name as any other entry.
+	sourcePaths := make(map[string]string)
+	nameTemplateTargetNames := make(map[string]bool)
+	addSourcePath := func(targetName, path string, nameTemplate bool) error {
+		if prevPath, ok := sourcePaths[targetName]; ok && (nameTemplate || nameTemplateTargetNames[targetName]) {
+			return fmt.Errorf("%s: duplicate source state entries %s and %s", targetName, prevPath, path)
+		}
+		sourcePaths[targetName] = path
+		if nameTemplate {
+			nameTemplateTargetNames[targetName] = true
+		}
+		return nil
+	}
+	err := vfs.Walk(fs, sourceDir, func(path string, info os.FileInfo, _ error) error {
+		relPath, err := filepath.Rel(sourceDir, path)
 		if err != nil {
 			return err
 		}
@@ -459,10 +615,21 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 		if _, name := filepath.Split(relPath); strings.HasPrefix(name, ".") {
 			switch {
 			case info.Name() == ignoreName:
-				dns := dirNames(parseDirNameComponents(splitPathList(relPath)))
+				dns, err := ts.dirNames(parseDirNameComponents(splitPathList(relPath)))
+				if err != nil {
+					return err
+				}
 				return ts.addPatterns(fs, ts.TargetIgnore, path, filepath.Join(dns...))
+			case info.Name() == dataName || strings.HasPrefix(info.Name(), dataName+"."):
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
 			case info.Name() == removeName:
-				dns := dirNames(parseDirNameComponents(splitPathList(relPath)))
+				dns, err := ts.dirNames(parseDirNameComponents(splitPathList(relPath)))
+				if err != nil {
+					return err
+				}
 				return ts.addPatterns(fs, ts.TargetRemove, path, filepath.Join(dns...))
 			case info.Name() == templatesDirName:
 				if err := ts.addTemplatesDir(fs, path); err != nil {
@@ -482,6 +649,14 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 					ts.MinVersion = version
 				}
 				return nil
+			case strings.HasPrefix(info.Name(), externalName+".") && !info.IsDir():
+				// Add externals after all other entries so that they can be
+				// added to directories in the source state.
+				externalFiles = append(externalFiles, externalFile{
+					sourceDir: sourceDir,
+					relPath:   relPath,
+				})
+				return nil
 			case info.IsDir():
 				// Don't recurse into ignored subdirectories.
 				return filepath.SkipDir
@@ -493,28 +668,69 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 		case info.IsDir():
 			components := splitPathList(relPath)
 			das := parseDirNameComponents(components)
-			dns := dirNames(das)
+			dns, err := ts.dirNames(das)
+			if err != nil {
+				return err
+			}
 			targetName := filepath.Join(dns...)
+			if err := addSourcePath(targetName, path, hasNameTemplate(das)); err != nil {
+				return err
+			}
 			entries, err := ts.findEntries(dns[:len(dns)-1])
 			if err != nil {
 				return err
 			}
 			da := das[len(das)-1]
-			entries[da.Name] = newDir(relPath, targetName, da.Exact, da.Perm)
+			da.Name = dns[len(dns)-1]
+			ts.applyDirAttributes(targetName, &da)
+			dir := newDir(relPath, targetName, da.Exact, da.Perm)
+			dir.Owner, dir.Group = ts.matchOwnership(targetName)
+			// Keep the entries of any directory in an earlier source
+			// directory.
+			if existingDir, ok := entries[da.Name].(*Dir); ok {
+				dir.Entries = existingDir.Entries
+			}
+			entries[da.Name] = dir
+			ts.setEntrySourceDir(targetName, sourceDir)
 		case info.Mode().IsRegular():
 			psfp := parseSourceFilePath(relPath)
-			dns := dirNames(psfp.dirAttributes)
+			dns, err := ts.dirNames(psfp.dirAttributes)
+			if err != nil {
+				return err
+			}
 			entries, err := ts.findEntries(dns)
 			if err != nil {
 				return err
 			}
+			if psfp.fileAttributes != nil {
+				psfp.fileAttributes.Name, err = ts.executeName(psfp.fileAttributes.Name, psfp.fileAttributes.NameTemplate)
+				if err != nil {
+					return err
+				}
+				targetName := filepath.Join(append(dns, psfp.fileAttributes.Name)...)
+				if err := addSourcePath(targetName, path, psfp.fileAttributes.NameTemplate || hasNameTemplate(psfp.dirAttributes)); err != nil {
+					return err
+				}
+				ts.applyFileAttributes(targetName, psfp.fileAttributes)
+			}
+			if psfp.scriptAttributes != nil {
+				psfp.scriptAttributes.Name, err = ts.executeName(psfp.scriptAttributes.Name, psfp.scriptAttributes.NameTemplate)
+				if err != nil {
+					return err
+				}
+				targetName := filepath.Join(append(dns, psfp.scriptAttributes.Name)...)
+				if err := addSourcePath(targetName, path, psfp.scriptAttributes.NameTemplate || hasNameTemplate(psfp.dirAttributes)); err != nil {
+					return err
+				}
+				ts.applyScriptAttributes(targetName, psfp.scriptAttributes)
+			}
 			switch {
 			case psfp.fileAttributes != nil && psfp.fileAttributes.Mode&os.ModeType == 0 || psfp.scriptAttributes != nil:
 				readFile := func() ([]byte, error) {
 					return fs.ReadFile(path)
 				}
 				evaluateContents := readFile
-				if psfp.fileAttributes != nil && psfp.fileAttributes.Encrypted {
+				if psfp.fileAttributes != nil && psfp.fileAttributes.Encrypted || psfp.scriptAttributes != nil && psfp.scriptAttributes.Encrypted {
 					prevEvaluateContents := evaluateContents
 					evaluateContents = func() ([]byte, error) {
 						ciphertext, err := prevEvaluateContents()
@@ -538,25 +754,46 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 				}
 				switch {
 				case psfp.fileAttributes != nil:
+					targetName := filepath.Join(append(dns, psfp.fileAttributes.Name)...)
+					if psfp.fileAttributes.Modify && (options == nil || options.ExecuteTemplates) {
+						prevEvaluateContents := evaluateContents
+						evaluateContents = func() ([]byte, error) {
+							modifier, err := prevEvaluateContents()
+							if err != nil {
+								return nil, err
+							}
+							return ts.runModifyScript(fs, targetName, modifier)
+						}
+					}
 					entry := &File{
 						sourceName:       relPath,
-						targetName:       filepath.Join(append(dns, psfp.fileAttributes.Name)...),
+						sourcePath:       path,
+						targetName:       targetName,
+						Create:           psfp.fileAttributes.Create,
 						Empty:            psfp.fileAttributes.Empty,
 						Encrypted:        psfp.fileAttributes.Encrypted,
+						Modify:           psfp.fileAttributes.Modify,
 						Perm:             psfp.fileAttributes.Mode.Perm(),
 						Template:         psfp.fileAttributes.Template,
 						evaluateContents: evaluateContents,
 					}
+					entry.Owner, entry.Group = ts.matchOwnership(targetName)
 					entries[psfp.fileAttributes.Name] = entry
+					ts.setEntrySourceDir(entry.targetName, sourceDir)
 				case psfp.scriptAttributes != nil:
 					entry := &Script{
 						sourceName:       relPath,
 						targetName:       filepath.Join(append(dns, psfp.scriptAttributes.Name)...),
+						Encrypted:        psfp.scriptAttributes.Encrypted,
 						Once:             psfp.scriptAttributes.Once,
+						OnChange:         psfp.scriptAttributes.OnChange,
+						Before:           psfp.scriptAttributes.Before,
+						After:            psfp.scriptAttributes.After,
 						Template:         psfp.scriptAttributes.Template,
 						evaluateContents: evaluateContents,
 					}
 					entries[psfp.scriptAttributes.Name] = entry
+					ts.setEntrySourceDir(entry.targetName, sourceDir)
 				}
 			case psfp.fileAttributes != nil && psfp.fileAttributes.Mode&os.ModeType == os.ModeSymlink:
 				evaluateLinkname := func() (string, error) {
@@ -576,6 +813,7 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 					evaluateLinkname: evaluateLinkname,
 				}
 				entries[psfp.fileAttributes.Name] = entry
+				ts.setEntrySourceDir(entry.targetName, sourceDir)
 			default:
 				return fmt.Errorf("%s: unsupported file type", path)
 			}
@@ -584,6 +822,22 @@ func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
 		}
 		return nil
 	})
+	return externalFiles, err
+}
+
+// ScriptEnv returns the environment variables that describe ts to scripts.
+func (ts *TargetState) ScriptEnv() ([]string, error) {
+	data, err := json.Marshal(ts.TemplateData)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"CHEZMOI_ARCH=" + runtime.GOARCH,
+		"CHEZMOI_DATA=" + string(data),
+		"CHEZMOI_DEST_DIR=" + ts.DestDir,
+		"CHEZMOI_OS=" + runtime.GOOS,
+		"CHEZMOI_SOURCE_DIR=" + ts.SourceDir,
+	}, nil
 }
 
 func (ts *TargetState) addDir(targetName string, entries map[string]Entry, parentDirSourceName string, exact bool, perm os.FileMode, createKeepFile bool, mutator Mutator) error {
@@ -603,6 +857,9 @@ func (ts *TargetState) addDir(targetName string, entries map[string]Entry, paren
 		sourceName = filepath.Join(parentDirSourceName, sourceName)
 	}
 	dir := newDir(sourceName, targetName, exact, perm)
+	if err := ts.mkdirParentSourceDir(parentDirSourceName, mutator); err != nil {
+		return err
+	}
 	if err := mutator.Mkdir(filepath.Join(ts.SourceDir, sourceName), 0o777&^ts.Umask); err != nil {
 		return err
 	}
@@ -612,10 +869,11 @@ func (ts *TargetState) addDir(targetName string, entries map[string]Entry, paren
 		}
 	}
 	entries[name] = dir
+	ts.setEntrySourceDir(targetName, ts.SourceDir)
 	return nil
 }
 
-func (ts *TargetState) addFile(targetName string, entries map[string]Entry, parentDirSourceName string, info os.FileInfo, perm os.FileMode, encrypted, template bool, contents []byte, mutator Mutator) error {
+func (ts *TargetState) addFile(targetName string, entries map[string]Entry, parentDirSourceName string, info os.FileInfo, perm os.FileMode, create, encrypted, template bool, contents []byte, mutator Mutator) error {
 	name := filepath.Base(targetName)
 	var existingFile *File
 	var existingContents []byte
@@ -632,19 +890,25 @@ func (ts *TargetState) addFile(targetName string, entries map[string]Entry, pare
 	}
 
 	empty := info.Size() == 0
-	sourceName := FileAttributes{
+	fa := FileAttributes{
 		Name:      name,
 		Mode:      perm,
+		Create:    create,
 		Empty:     empty,
 		Encrypted: encrypted,
 		Template:  template,
-	}.SourceName()
+	}
+	if existingFile != nil {
+		keepNameTemplate(&fa, existingFile.sourceName)
+	}
+	sourceName := fa.SourceName()
 	if parentDirSourceName != "" {
 		sourceName = filepath.Join(parentDirSourceName, sourceName)
 	}
 	file := &File{
 		sourceName: sourceName,
 		targetName: targetName,
+		Create:     create,
 		Empty:      empty,
 		Encrypted:  encrypted,
 		Perm:       perm,
@@ -652,18 +916,114 @@ func (ts *TargetState) addFile(targetName string, entries map[string]Entry, pare
 		contents:   contents,
 	}
 	if existingFile != nil {
-		if bytes.Equal(existingFile.contents, file.contents) {
-			if existingFile.sourceName == file.sourceName {
+		// Existing files in other source directories are overridden, not
+		// renamed or removed.
+		writable := ts.EntrySourceDir(existingFile) == ts.SourceDir
+		switch {
+		case bytes.Equal(existingFile.contents, file.contents) && existingFile.sourceName == file.sourceName:
+			return nil
+		case bytes.Equal(existingFile.contents, file.contents) && writable:
+			return mutator.Rename(filepath.Join(ts.SourceDir, existingFile.sourceName), filepath.Join(ts.SourceDir, file.sourceName))
+		case writable:
+			if err := mutator.RemoveAll(filepath.Join(ts.SourceDir, existingFile.sourceName)); err != nil {
+				return err
+			}
+		default:
+			existingContents = nil
+		}
+	}
+	entries[name] = file
+	ts.setEntrySourceDir(targetName, ts.SourceDir)
+	if err := ts.mkdirParentSourceDir(parentDirSourceName, mutator); err != nil {
+		return err
+	}
+	return mutator.WriteFile(filepath.Join(ts.SourceDir, sourceName), contents, 0o666&^ts.Umask, existingContents)
+}
+
+// hasNameTemplate returns true if any of dirAttributes has a name template.
+func hasNameTemplate(dirAttributes []DirAttributes) bool {
+	for _, da := range dirAttributes {
+		if da.NameTemplate {
+			return true
+		}
+	}
+	return false
+}
+
+// keepNameTemplate sets fa's name to the name template of the existing source
+// name sourceName, if it has one, so that re-adding a target does not replace
+// its name template with the current target name.
+func keepNameTemplate(fa *FileAttributes, sourceName string) {
+	if existingFA := ParseFileAttributes(filepath.Base(sourceName)); existingFA.NameTemplate {
+		fa.Name = existingFA.Name
+		fa.NameTemplate = true
+	}
+}
+
+// mkdirParentSourceDir ensures that the parent directory parentDirSourceName
+// exists in ts.SourceDir. It is only needed if ts has multiple source
+// directories, as the parent directory might only exist in another source
+// directory.
+func (ts *TargetState) mkdirParentSourceDir(parentDirSourceName string, mutator Mutator) error {
+	if parentDirSourceName == "" || ts.entrySourceDirs == nil {
+		return nil
+	}
+	return vfs.MkdirAll(mutator, filepath.Join(ts.SourceDir, parentDirSourceName), 0o777&^ts.Umask)
+}
+
+// addTemplateData merges the data in all .chezmoidata.<format> files and
+// .chezmoidata directories in sourceDirs into ts's template data. Data already
+// in ts's template data takes precedence.
+func (ts *TargetState) addTemplateData(fs vfs.FS, sourceDirs []string) error {
+	var dataPaths []string
+	for _, sourceDir := range sourceDirs {
+		sourceDir := sourceDir
+		if err := vfs.Walk(fs, sourceDir, func(path string, info os.FileInfo, _ error) error {
+			if path == sourceDir {
 				return nil
 			}
-			return mutator.Rename(filepath.Join(ts.SourceDir, existingFile.sourceName), filepath.Join(ts.SourceDir, file.sourceName))
+			name := info.Name()
+			switch {
+			case name == dataName && info.IsDir():
+				return vfs.Walk(fs, path, func(path string, info os.FileInfo, _ error) error {
+					if info.Mode().IsRegular() {
+						dataPaths = append(dataPaths, path)
+					}
+					return nil
+				})
+			case strings.HasPrefix(name, dataName+".") && info.Mode().IsRegular():
+				dataPaths = append(dataPaths, path)
+			case strings.HasPrefix(name, ".") && info.IsDir():
+				return filepath.SkipDir
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if len(dataPaths) == 0 {
+		return nil
+	}
+
+	templateData := make(map[string]interface{})
+	for _, path := range dataPaths {
+		decode, ok := formatDecoders[strings.TrimPrefix(filepath.Ext(path), ".")]
+		if !ok {
+			return fmt.Errorf("%s: unknown format", path)
 		}
-		if err := mutator.RemoveAll(filepath.Join(ts.SourceDir, existingFile.sourceName)); err != nil {
+		data, err := fs.ReadFile(path)
+		if err != nil {
 			return err
 		}
+		var value map[string]interface{}
+		if err := decode(data, &value); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		recursiveMerge(templateData, normalizeMaps(value).(map[string]interface{}))
 	}
-	entries[name] = file
-	return mutator.WriteFile(filepath.Join(ts.SourceDir, sourceName), contents, 0o666&^ts.Umask, existingContents)
+	recursiveMerge(templateData, ts.TemplateData)
+	ts.TemplateData = templateData
+	return nil
 }
 
 func (ts *TargetState) addPatterns(fs vfs.FS, ps *PatternSet, path, relPath string) error {
@@ -713,10 +1073,14 @@ func (ts *TargetState) addSymlink(targetName string, entries map[string]Entry, p
 			return err
 		}
 	}
-	sourceName := FileAttributes{
+	fa := FileAttributes{
 		Name: name,
 		Mode: os.ModeSymlink,
-	}.SourceName()
+	}
+	if existingSymlink != nil {
+		keepNameTemplate(&fa, existingSymlink.sourceName)
+	}
+	sourceName := fa.SourceName()
 	if parentDirSourceName != "" {
 		sourceName = filepath.Join(parentDirSourceName, sourceName)
 	}
@@ -726,17 +1090,27 @@ func (ts *TargetState) addSymlink(targetName string, entries map[string]Entry, p
 		linkname:   linkname,
 	}
 	if existingSymlink != nil {
-		if existingSymlink.linkname == symlink.linkname {
-			if existingSymlink.sourceName == symlink.sourceName {
-				return nil
-			}
+		// Existing symlinks in other source directories are overridden, not
+		// renamed or removed.
+		writable := ts.EntrySourceDir(existingSymlink) == ts.SourceDir
+		switch {
+		case existingSymlink.linkname == symlink.linkname && existingSymlink.sourceName == symlink.sourceName:
+			return nil
+		case existingSymlink.linkname == symlink.linkname && writable:
 			return mutator.Rename(filepath.Join(ts.SourceDir, existingSymlink.sourceName), filepath.Join(ts.SourceDir, symlink.sourceName))
-		}
-		if err := mutator.RemoveAll(filepath.Join(ts.SourceDir, existingSymlink.sourceName)); err != nil {
-			return err
+		case writable:
+			if err := mutator.RemoveAll(filepath.Join(ts.SourceDir, existingSymlink.sourceName)); err != nil {
+				return err
+			}
+		default:
+			existingLinkname = ""
 		}
 	}
 	entries[name] = symlink
+	ts.setEntrySourceDir(targetName, ts.SourceDir)
+	if err := ts.mkdirParentSourceDir(parentDirSourceName, mutator); err != nil {
+		return err
+	}
 	return mutator.WriteFile(filepath.Join(ts.SourceDir, symlink.sourceName), []byte(symlink.linkname), 0o666&^ts.Umask, []byte(existingLinkname))
 }
 
@@ -778,6 +1152,97 @@ func (ts *TargetState) executeTemplate(fs vfs.FS, path string) ([]byte, error) {
 	return ts.ExecuteTemplateData(path, data)
 }
 
+// dirNames returns the target names of dirAttributes, executing any name
+// templates.
+func (ts *TargetState) dirNames(dirAttributes []DirAttributes) ([]string, error) {
+	dns := make([]string, len(dirAttributes))
+	for i, da := range dirAttributes {
+		dn, err := ts.executeName(da.Name, da.NameTemplate)
+		if err != nil {
+			return nil, err
+		}
+		dns[i] = dn
+	}
+	return dns, nil
+}
+
+// executeName returns the target name name. If nameTemplate is true then name
+// is executed as a template first.
+func (ts *TargetState) executeName(name string, nameTemplate bool) (string, error) {
+	if !nameTemplate {
+		return name, nil
+	}
+	data, err := ts.ExecuteTemplateData(name, []byte(name))
+	if err != nil {
+		return "", err
+	}
+	targetName := string(data)
+	if targetName == "" || targetName == "." || targetName == ".." || strings.ContainsAny(targetName, `/\`) {
+		return "", fmt.Errorf("%s: invalid target name %q", name, targetName)
+	}
+	return targetName, nil
+}
+
+// runModifyScript runs modifier with the current contents of targetName on its
+// standard input and returns its standard output as the new contents. modifier
+// is run with the interpreter for targetName, if any.
+func (ts *TargetState) runModifyScript(fs vfs.FS, targetName string, modifier []byte) ([]byte, error) {
+	targetPath := ts.TargetPath(targetName)
+	currentContents, err := fs.ReadFile(targetPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if isEmpty(modifier) {
+		return currentContents, nil
+	}
+
+	// Run the modify script in the target's parent directory if it exists,
+	// otherwise in the destination directory.
+	dir := filepath.Dir(targetPath)
+	if info, err := fs.Stat(dir); err != nil || !info.IsDir() {
+		dir = ts.DestDir
+	}
+	rawDir, err := fs.RawPath(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the temporary modify script file. Put the randomness on the front
+	// of the filename to preserve any file extension for Windows scripts.
+	f, err := ioutil.TempFile("", "*."+filepath.Base(targetName))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(f.Name())
+	}()
+	if err := os.Chmod(f.Name(), 0o700); err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(modifier); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	env, err := ts.ScriptEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := findInterpreter(ts.Interpreters, targetName).ExecCommand(f.Name())
+	cmd.Dir = rawDir
+	cmd.Env = scriptEnv(env, targetPath)
+	cmd.Stdin = bytes.NewReader(currentContents)
+	cmd.Stderr = os.Stderr
+	contents, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", targetName, err)
+	}
+	return contents, nil
+}
+
 func (ts *TargetState) findEntries(dirNames []string) (map[string]Entry, error) {
 	entries := ts.Entries
 	for i, dirName := range dirNames {
@@ -805,6 +1270,14 @@ func (ts *TargetState) findEntry(name string) (Entry, error) {
 	return entry, nil
 }
 
+// setEntrySourceDir records that the entry with targetName was read from
+// sourceDir, if ts has multiple source directories.
+func (ts *TargetState) setEntrySourceDir(targetName, sourceDir string) {
+	if ts.entrySourceDirs != nil {
+		ts.entrySourceDirs[targetName] = sourceDir
+	}
+}
+
 func (ts *TargetState) importHeader(r io.Reader, importTAROptions ImportTAROptions, header *tar.Header, mutator Mutator) error {
 	targetPath := header.Name
 	if importTAROptions.StripComponents > 0 {
@@ -846,7 +1319,7 @@ func (ts *TargetState) importHeader(r io.Reader, importTAROptions ImportTAROptio
 		if err != nil {
 			return err
 		}
-		return ts.addFile(targetName, entries, parentDirSourceName, info, info.Mode().Perm(), false, false, contents, mutator)
+		return ts.addFile(targetName, entries, parentDirSourceName, info, info.Mode().Perm(), false, false, false, contents, mutator)
 	case tar.TypeSymlink:
 		linkname := header.Linkname
 		return ts.addSymlink(targetName, entries, parentDirSourceName, linkname, mutator)
@@ -3,6 +3,7 @@ package chezmoi
 import (
 	"os"
 	"path/filepath"
+	"sort"
 	"testing"
 	"text/template"
 
@@ -141,6 +142,7 @@ func TestTargetStatePopulate(t *testing.T) {
 				WithEntries(map[string]Entry{
 					"foo": &File{
 						sourceName: "foo",
+						sourcePath: "/foo",
 						targetName: "foo",
 						Perm:       0o666,
 						contents:   []byte("bar"),
@@ -159,6 +161,7 @@ func TestTargetStatePopulate(t *testing.T) {
 				WithEntries(map[string]Entry{
 					".foo": &File{
 						sourceName: "dot_foo",
+						sourcePath: "/dot_foo",
 						targetName: ".foo",
 						Perm:       0o666,
 						contents:   []byte("bar"),
@@ -178,6 +181,7 @@ func TestTargetStatePopulate(t *testing.T) {
 				WithEntries(map[string]Entry{
 					"foo": &File{
 						sourceName: "private_foo",
+						sourcePath: "/private_foo",
 						targetName: "foo",
 						Perm:       0o600,
 						contents:   []byte("bar"),
@@ -203,6 +207,7 @@ func TestTargetStatePopulate(t *testing.T) {
 						Entries: map[string]Entry{
 							"bar": &File{
 								sourceName: filepath.Join("foo", "bar"),
+								sourcePath: filepath.Join("/", "foo", "bar"),
 								targetName: filepath.Join("foo", "bar"),
 								Perm:       0o666,
 								contents:   []byte("baz"),
@@ -230,6 +235,7 @@ func TestTargetStatePopulate(t *testing.T) {
 						Entries: map[string]Entry{
 							"bar": &File{
 								sourceName: filepath.Join("private_dot_foo", "bar"),
+								sourcePath: filepath.Join("/", "private_dot_foo", "bar"),
 								targetName: filepath.Join(".foo", "bar"),
 								Perm:       0o666,
 								contents:   []byte("baz"),
@@ -254,6 +260,7 @@ func TestTargetStatePopulate(t *testing.T) {
 				WithEntries(map[string]Entry{
 					".gitconfig": &File{
 						sourceName: "dot_gitconfig.tmpl",
+						sourcePath: "/dot_gitconfig.tmpl",
 						targetName: ".gitconfig",
 						Perm:       0o666,
 						Template:   true,
@@ -283,6 +290,7 @@ func TestTargetStatePopulate(t *testing.T) {
 						Entries: map[string]Entry{
 							"foo": &File{
 								sourceName: filepath.Join("exact_dir", "foo"),
+								sourcePath: filepath.Join("/", "exact_dir", "foo"),
 								targetName: filepath.Join("dir", "foo"),
 								Perm:       0o666,
 								contents:   []byte("bar"),
@@ -437,6 +445,7 @@ func TestTargetStatePopulate(t *testing.T) {
 				WithEntries(map[string]Entry{
 					"foo": &File{
 						sourceName: "foo",
+						sourcePath: "/foo",
 						targetName: "foo",
 						Perm:       0o666,
 						contents:   []byte("bar"),
@@ -488,3 +497,278 @@ func TestTargetStatePopulate(t *testing.T) {
 		})
 	}
 }
+
+func TestTargetStatePopulateTemplateData(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/.chezmoidata.toml": "" +
+			"email = \"user@example.com\"\n" +
+			"[git]\n" +
+			"    editor = \"vim\"\n" +
+			"    signingKey = \"ABCDEF\"\n",
+		"/.chezmoidata/git.yaml": "" +
+			"git:\n" +
+			"  editor: vi\n" +
+			"  pager: less\n",
+		"/dir/.chezmoidata.json":  `{"dir": true}`,
+		"/.git/.chezmoidata.json": `{"git": "ignored"}`,
+		"/dot_gitconfig.tmpl":     "{{ .email }} {{ .git.editor }} {{ .git.pager }} {{ .git.signingKey }}",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/"),
+		WithSourceDir("/"),
+		WithTemplateData(map[string]interface{}{
+			"email": "me@example.com",
+			"git": map[string]interface{}{
+				"signingKey": "123456",
+			},
+		}),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	assert.Equal(t, map[string]interface{}{
+		"dir":   true,
+		"email": "me@example.com",
+		"git": map[string]interface{}{
+			"editor":     "vim",
+			"pager":      "less",
+			"signingKey": "123456",
+		},
+	}, ts.TemplateData)
+	contents, err := ts.Entries[".gitconfig"].(*File).Contents()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("me@example.com vim less 123456"), contents)
+}
+
+func TestTargetStatePopulateSourceDirs(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/base": map[string]interface{}{
+			".chezmoiignore":      "foo\n",
+			".chezmoitemplates/a": "base a",
+			".chezmoitemplates/b": "base b",
+			"dot_bashrc":          "# base .bashrc\n",
+			"dot_config/file":     "# base .config/file\n",
+			"dot_vimrc":           "# base .vimrc\n",
+		},
+		"/home/user/.config/file": "# new .config/file\n",
+		"/personal": map[string]interface{}{
+			".chezmoiignore":      "bar\n",
+			".chezmoitemplates/b": "personal b",
+			"dot_config/other":    "# personal .config/other\n",
+			"private_dot_vimrc":   "# personal .vimrc\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/personal"),
+		WithSourceDirs([]string{"/base", "/personal"}),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+
+	assert.True(t, ts.TargetIgnore.Match("foo"))
+	assert.True(t, ts.TargetIgnore.Match("bar"))
+	assert.Equal(t, []string{"a", "b"}, sortedTemplateNames(ts.Templates))
+	assert.Equal(t, "personal b", ts.Templates["b"].Root.String())
+
+	for _, tc := range []struct {
+		targetName string
+		sourcePath string
+		perm       os.FileMode
+	}{
+		{
+			targetName: ".bashrc",
+			sourcePath: "/base/dot_bashrc",
+			perm:       0o666,
+		},
+		{
+			targetName: ".config",
+			sourcePath: "/personal/dot_config",
+			perm:       0o777,
+		},
+		{
+			targetName: filepath.Join(".config", "file"),
+			sourcePath: filepath.Join("/base", "dot_config", "file"),
+			perm:       0o666,
+		},
+		{
+			targetName: filepath.Join(".config", "other"),
+			sourcePath: filepath.Join("/personal", "dot_config", "other"),
+			perm:       0o666,
+		},
+		{
+			targetName: ".vimrc",
+			sourcePath: "/personal/private_dot_vimrc",
+			perm:       0o600,
+		},
+	} {
+		t.Run(tc.targetName, func(t *testing.T) {
+			entry, err := ts.findEntry(tc.targetName)
+			require.NoError(t, err)
+			assert.Equal(t, tc.sourcePath, filepath.Join(ts.EntrySourceDir(entry), entry.SourceName()))
+			switch entry := entry.(type) {
+			case *Dir:
+				assert.Equal(t, tc.perm, entry.Perm)
+			case *File:
+				assert.Equal(t, tc.perm, entry.Perm)
+			}
+		})
+	}
+
+	// Adding a file that is in another source directory adds it to the
+	// writable source directory.
+	require.NoError(t, ts.Add(fs, AddOptions{}, "/home/user/.config/file", nil, false, NewFSMutator(fs)))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/base/dot_config/file",
+			vfst.TestContentsString("# base .config/file\n"),
+		),
+		vfst.TestPath("/personal/dot_config/file",
+			vfst.TestContentsString("# new .config/file\n"),
+		),
+	)
+}
+
+func TestTargetStatePopulateNameTemplates(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/myhost/file": "# new file\n",
+		"/source": map[string]interface{}{
+			".chezmoiignore": "{{ .host }}/ignored\n",
+			"dot_ssh/config.d/nametmpl_{{ .host }}.conf": "# {{ .host }}\n",
+			"dot_ssh/config.d/other.conf":                "# other\n",
+			"nametmpl_{{ .host }}/file":                  "# file\n",
+			"nametmpl_{{ .host }}/ignored":               "# ignored\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/source"),
+		WithTemplateData(map[string]interface{}{
+			"host": "myhost",
+		}),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	assert.True(t, ts.TargetIgnore.Match(filepath.Join("myhost", "ignored")))
+	for targetName, sourceName := range map[string]string{
+		filepath.Join(".ssh", "config.d", "myhost.conf"): filepath.Join("dot_ssh", "config.d", "nametmpl_{{ .host }}.conf"),
+		filepath.Join(".ssh", "config.d", "other.conf"):  filepath.Join("dot_ssh", "config.d", "other.conf"),
+		"myhost":                        "nametmpl_{{ .host }}",
+		filepath.Join("myhost", "file"): filepath.Join("nametmpl_{{ .host }}", "file"),
+	} {
+		entry, err := ts.findEntry(targetName)
+		require.NoError(t, err, targetName)
+		assert.Equal(t, targetName, entry.TargetName())
+		assert.Equal(t, sourceName, entry.SourceName())
+	}
+
+	// Re-adding a target keeps its name template.
+	require.NoError(t, ts.Add(fs, AddOptions{}, "/home/user/myhost/file", nil, false, NewFSMutator(fs)))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/source/nametmpl_{{ .host }}/file",
+			vfst.TestContentsString("# new file\n"),
+		),
+	)
+
+	// Entries that render to the same target are an error.
+	require.NoError(t, fs.WriteFile("/source/dot_ssh/config.d/nametmpl_other.conf", nil, 0o644))
+	ts = NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/source"),
+		WithTemplateData(map[string]interface{}{
+			"host": "other",
+		}),
+	)
+	err = ts.Populate(fs, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate source state entries")
+
+	// Names that render to paths are an error.
+	ts = NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/source"),
+		WithTemplateData(map[string]interface{}{
+			"host": "../other",
+		}),
+	)
+	err = ts.Populate(fs, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid target name")
+}
+
+func sortedTemplateNames(templates map[string]*template.Template) []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestTargetStateApplySymlinkMode(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc": "# old .bashrc\n",
+			".local/share/chezmoi": map[string]interface{}{
+				"dot_bashrc":        "# contents of .bashrc\n",
+				"dot_gitconfig":     "# contents of .gitconfig\n",
+				"dot_profile.tmpl":  "# {{ .user }}\n",
+				"executable_bin":    "#!/bin/sh\n",
+				"private_dot_netrc": "# contents of .netrc\n",
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/home/user/.local/share/chezmoi"),
+		WithTemplateData(map[string]interface{}{
+			"user": "user",
+		}),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	applyOptions := &ApplyOptions{
+		DestDir: ts.DestDir,
+		Ignore:  ts.TargetIgnore.Match,
+		Mode:    ModeSymlink,
+		Stdout:  os.Stdout,
+		Umask:   0o22,
+	}
+	require.NoError(t, ts.Apply(fs, NewFSMutator(fs), false, applyOptions))
+
+	rawSourceDir, err := fs.RawPath("/home/user/.local/share/chezmoi")
+	require.NoError(t, err)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestModeType(os.ModeSymlink),
+			vfst.TestSymlinkTarget(filepath.Join(rawSourceDir, "dot_bashrc")),
+		),
+		vfst.TestPath("/home/user/.gitconfig",
+			vfst.TestModeType(os.ModeSymlink),
+			vfst.TestSymlinkTarget(filepath.Join(rawSourceDir, "dot_gitconfig")),
+		),
+		vfst.TestPath("/home/user/.profile",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# user\n"),
+		),
+		vfst.TestPath("/home/user/bin",
+			vfst.TestModeIsRegular,
+			vfst.TestModePerm(0o755),
+		),
+		vfst.TestPath("/home/user/.netrc",
+			vfst.TestModeIsRegular,
+			vfst.TestModePerm(0o600),
+		),
+	)
+
+	// Applying again makes no changes.
+	anyMutator := NewAnyMutator(NullMutator{})
+	require.NoError(t, ts.Apply(fs, anyMutator, false, applyOptions))
+	assert.False(t, anyMutator.Mutated())
+}
@@ -0,0 +1,229 @@
+package chezmoi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// A TemplateConflict is a change to the output of a template that cannot be
+// applied to the template because it changes lines generated by template
+// actions.
+type TemplateConflict struct {
+	TemplateStartLine int
+	TemplateLines     []string
+	RenderedLines     []string
+	EditedLines       []string
+}
+
+// A lineHunk is a change that replaces the lines [aStart, aEnd) of a with the
+// lines [bStart, bEnd) of b.
+type lineHunk struct {
+	aStart, aEnd int
+	bStart, bEnd int
+}
+
+// PatchTemplate applies the changes from rendered, the output of template, to
+// edited to template. Changes to lines that are copied literally from template
+// are applied. If any change touches lines that are generated by template
+// actions then template is returned unchanged with the conflicting changes.
+func PatchTemplate(template, rendered, edited []byte) ([]byte, []*TemplateConflict) {
+	templateLines := splitLines(template)
+	renderedLines := splitLines(rendered)
+	editedLines := splitLines(edited)
+
+	// Map each rendered line that is copied literally from the template to its
+	// index in the template, and all other rendered lines to -1.
+	renderedToTemplate := make([]int, len(renderedLines))
+	for i := range renderedToTemplate {
+		renderedToTemplate[i] = -1
+	}
+	diffLines(templateLines, renderedLines, func(templateIndex, renderedIndex int) {
+		renderedToTemplate[renderedIndex] = templateIndex
+	})
+
+	var patchedLines []string
+	var conflicts []*TemplateConflict
+	templateIndex := 0
+	for _, hunk := range diffLines(renderedLines, editedLines, nil) {
+		// A hunk that changes lines can be applied if the lines are literal
+		// and consecutive in the template. A hunk that only inserts lines can
+		// be applied if there are no template actions between the lines
+		// before and after it.
+		var start int
+		if hunk.aStart < hunk.aEnd {
+			start = renderedToTemplate[hunk.aStart]
+			for i := hunk.aStart; start != -1 && i < hunk.aEnd; i++ {
+				if renderedToTemplate[i] != start+i-hunk.aStart {
+					start = -1
+				}
+			}
+		} else {
+			start = insertionPoint(hunk.aStart, len(templateLines), renderedToTemplate)
+		}
+		if start == -1 {
+			conflicts = append(conflicts, newHunkTemplateConflict(hunk, templateLines, renderedLines, editedLines, renderedToTemplate))
+			continue
+		}
+		patchedLines = append(patchedLines, templateLines[templateIndex:start]...)
+		patchedLines = append(patchedLines, editedLines[hunk.bStart:hunk.bEnd]...)
+		templateIndex = start + hunk.aEnd - hunk.aStart
+	}
+	if len(conflicts) != 0 {
+		return template, conflicts
+	}
+	patchedLines = append(patchedLines, templateLines[templateIndex:]...)
+	return []byte(strings.Join(patchedLines, "")), nil
+}
+
+// String returns a report of c in the style of a diff3 conflict.
+func (c *TemplateConflict) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "<<<<<<< template (line %d)\n", c.TemplateStartLine)
+	writeConflictLines(sb, c.TemplateLines)
+	sb.WriteString("||||||| rendered\n")
+	writeConflictLines(sb, c.RenderedLines)
+	sb.WriteString("=======\n")
+	writeConflictLines(sb, c.EditedLines)
+	sb.WriteString(">>>>>>> edited\n")
+	return sb.String()
+}
+
+// diffLines returns the hunks that change a into b. If equal is not nil then
+// it is called with the indexes of each line that is the same in a and b.
+func diffLines(a, b []string, equal func(i, j int)) []*lineHunk {
+	// Map each distinct line to a rune so that the lines can be diffed as
+	// strings of runes.
+	lineRunes := make(map[string]rune)
+	toRunes := func(lines []string) []rune {
+		runes := make([]rune, 0, len(lines))
+		for _, line := range lines {
+			r, ok := lineRunes[line]
+			if !ok {
+				r = rune(len(lineRunes))
+				lineRunes[line] = r
+			}
+			runes = append(runes, r)
+		}
+		return runes
+	}
+	aRunes := toRunes(a)
+	bRunes := toRunes(b)
+
+	var hunks []*lineHunk
+	var hunk *lineHunk
+	i, j := 0, 0
+	for _, diff := range diffmatchpatch.New().DiffMainRunes(aRunes, bRunes, false) {
+		n := len([]rune(diff.Text))
+		switch diff.Type {
+		case diffmatchpatch.DiffEqual:
+			if equal != nil {
+				for k := 0; k < n; k++ {
+					equal(i+k, j+k)
+				}
+			}
+			i += n
+			j += n
+			hunk = nil
+			continue
+		case diffmatchpatch.DiffDelete:
+			if hunk == nil {
+				hunk = &lineHunk{aStart: i, aEnd: i, bStart: j, bEnd: j}
+				hunks = append(hunks, hunk)
+			}
+			i += n
+		case diffmatchpatch.DiffInsert:
+			if hunk == nil {
+				hunk = &lineHunk{aStart: i, aEnd: i, bStart: j, bEnd: j}
+				hunks = append(hunks, hunk)
+			}
+			j += n
+		}
+		hunk.aEnd = i
+		hunk.bEnd = j
+	}
+	return hunks
+}
+
+// insertionPoint returns the index in the template at which lines inserted
+// before rendered line i should be inserted, or -1 if the lines before and
+// after the insertion are not adjacent literal lines in the template. Lines
+// inserted at the end or the start of the output of a template can always be
+// inserted at the end or start of the template.
+func insertionPoint(i, templateLen int, renderedToTemplate []int) int {
+	switch {
+	case i == len(renderedToTemplate):
+		return templateLen
+	case i == 0:
+		return 0
+	case renderedToTemplate[i-1] == -1 || renderedToTemplate[i-1]+1 != renderedToTemplate[i]:
+		return -1
+	default:
+		return renderedToTemplate[i]
+	}
+}
+
+// NewTemplateConflict returns a new TemplateConflict that replaces all of
+// rendered, the output of template, with edited.
+func NewTemplateConflict(template, rendered, edited []byte) *TemplateConflict {
+	return &TemplateConflict{
+		TemplateStartLine: 1,
+		TemplateLines:     splitLines(template),
+		RenderedLines:     splitLines(rendered),
+		EditedLines:       splitLines(edited),
+	}
+}
+
+// newHunkTemplateConflict returns a new TemplateConflict for hunk, including
+// the template lines that generate the rendered lines that hunk changes.
+func newHunkTemplateConflict(hunk *lineHunk, templateLines, renderedLines, editedLines []string, renderedToTemplate []int) *TemplateConflict {
+	templateStart := 0
+	for i := hunk.aStart - 1; i >= 0; i-- {
+		if renderedToTemplate[i] != -1 {
+			templateStart = renderedToTemplate[i] + 1
+			break
+		}
+	}
+	templateEnd := len(templateLines)
+	for i := hunk.aEnd; i < len(renderedLines); i++ {
+		if renderedToTemplate[i] != -1 {
+			templateEnd = renderedToTemplate[i]
+			break
+		}
+	}
+	return &TemplateConflict{
+		TemplateStartLine: templateStart + 1,
+		TemplateLines:     templateLines[templateStart:templateEnd],
+		RenderedLines:     renderedLines[hunk.aStart:hunk.aEnd],
+		EditedLines:       editedLines[hunk.bStart:hunk.bEnd],
+	}
+}
+
+// splitLines splits data into lines, each including its trailing newline, if
+// any.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) != 0 {
+		index := bytes.IndexByte(data, '\n')
+		if index == -1 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:index+1]))
+		data = data[index+1:]
+	}
+	return lines
+}
+
+// writeConflictLines writes lines to sb, ensuring that the last line ends with
+// a newline.
+func writeConflictLines(sb *strings.Builder, lines []string) {
+	for _, line := range lines {
+		sb.WriteString(line)
+	}
+	if len(lines) != 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		sb.WriteString("\n")
+	}
+}
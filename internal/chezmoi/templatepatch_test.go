@@ -0,0 +1,138 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		template          string
+		rendered          string
+		edited            string
+		expectedPatched   string
+		expectedConflicts []*TemplateConflict
+	}{
+		{
+			name:            "unchanged",
+			template:        "a\n{{ .b }}\nc\n",
+			rendered:        "a\nB\nc\n",
+			edited:          "a\nB\nc\n",
+			expectedPatched: "a\n{{ .b }}\nc\n",
+		},
+		{
+			name:            "change_literal",
+			template:        "a\n{{ .b }}\nc\n",
+			rendered:        "a\nB\nc\n",
+			edited:          "A\nB\nc\n",
+			expectedPatched: "A\n{{ .b }}\nc\n",
+		},
+		{
+			name:            "delete_literal",
+			template:        "a\n{{ .b }}\nc\n",
+			rendered:        "a\nB\nc\n",
+			edited:          "a\nB\n",
+			expectedPatched: "a\n{{ .b }}\n",
+		},
+		{
+			name:            "insert_between_literals",
+			template:        "a\nb\n{{ .c }}\n",
+			rendered:        "a\nb\nC\n",
+			edited:          "a\nx\nb\nC\n",
+			expectedPatched: "a\nx\nb\n{{ .c }}\n",
+		},
+		{
+			name:            "append",
+			template:        "a\n{{ .b }}\n",
+			rendered:        "a\nB\n",
+			edited:          "a\nB\nc\n",
+			expectedPatched: "a\n{{ .b }}\nc\n",
+		},
+		{
+			name:            "prepend",
+			template:        "{{ .a }}\nb\n",
+			rendered:        "A\nb\n",
+			edited:          "x\nA\nb\n",
+			expectedPatched: "x\n{{ .a }}\nb\n",
+		},
+		{
+			name:            "empty",
+			template:        "",
+			rendered:        "",
+			edited:          "a\n",
+			expectedPatched: "a\n",
+		},
+		{
+			name:            "multiple",
+			template:        "a\n{{ if true }}\nb\n{{ end }}\nc\n",
+			rendered:        "a\nb\nc\n",
+			edited:          "A\nb\nC\n",
+			expectedPatched: "A\n{{ if true }}\nb\n{{ end }}\nC\n",
+		},
+		{
+			name:     "change_action",
+			template: "a\n{{ .b }}\nc\n",
+			rendered: "a\nB\nc\n",
+			edited:   "a\nX\nc\n",
+			expectedConflicts: []*TemplateConflict{
+				{
+					TemplateStartLine: 2,
+					TemplateLines:     []string{"{{ .b }}\n"},
+					RenderedLines:     []string{"B\n"},
+					EditedLines:       []string{"X\n"},
+				},
+			},
+		},
+		{
+			name:     "insert_next_to_action",
+			template: "a\n{{ if true }}\nb\n{{ end }}\n",
+			rendered: "a\nb\n",
+			edited:   "a\nx\nb\n",
+			expectedConflicts: []*TemplateConflict{
+				{
+					TemplateStartLine: 2,
+					TemplateLines:     []string{"{{ if true }}\n"},
+					RenderedLines:     []string{},
+					EditedLines:       []string{"x\n"},
+				},
+			},
+		},
+		{
+			name:     "conflict_prevents_other_changes",
+			template: "a\n{{ .b }}\nc\n",
+			rendered: "a\nB\nc\n",
+			edited:   "A\nX\nc\nd\n",
+			expectedConflicts: []*TemplateConflict{
+				{
+					TemplateStartLine: 1,
+					TemplateLines:     []string{"a\n", "{{ .b }}\n"},
+					RenderedLines:     []string{"a\n", "B\n"},
+					EditedLines:       []string{"A\n", "X\n"},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			patched, conflicts := PatchTemplate([]byte(tc.template), []byte(tc.rendered), []byte(tc.edited))
+			if tc.expectedConflicts != nil {
+				assert.Equal(t, tc.expectedConflicts, conflicts)
+				assert.Equal(t, tc.template, string(patched))
+			} else {
+				assert.Nil(t, conflicts)
+				assert.Equal(t, tc.expectedPatched, string(patched))
+			}
+		})
+	}
+}
+
+func TestTemplateConflictString(t *testing.T) {
+	c := &TemplateConflict{
+		TemplateStartLine: 2,
+		TemplateLines:     []string{"{{ .b }}\n"},
+		RenderedLines:     []string{"B"},
+		EditedLines:       []string{"X\n"},
+	}
+	assert.Equal(t, "<<<<<<< template (line 2)\n{{ .b }}\n||||||| rendered\nB\n=======\nX\n>>>>>>> edited\n", c.String())
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/diff"
@@ -44,6 +45,25 @@ func (m *VerboseMutator) Chmod(name string, mode os.FileMode) error {
 	return err
 }
 
+// Chown implements Mutator.Chown.
+func (m *VerboseMutator) Chown(name string, uid, gid int) error {
+	var owner string
+	if uid != -1 {
+		owner = strconv.Itoa(uid)
+	}
+	if gid != -1 {
+		owner += ":" + strconv.Itoa(gid)
+	}
+	action := fmt.Sprintf("chown %s %s", owner, MaybeShellQuote(name))
+	err := m.m.Chown(name, uid, gid)
+	if err == nil {
+		_, _ = fmt.Fprintln(m.w, action)
+	} else {
+		_, _ = fmt.Fprintf(m.w, "%s: %v\n", action, err)
+	}
+	return err
+}
+
 // IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
 func (m *VerboseMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
 	action := cmdString(cmd)